@@ -2,23 +2,167 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/Artfain/triad-networks/core"
 )
 
-func SetupREST(state *core.State) {
-	http.HandleFunc("/blocks", func(w http.ResponseWriter, r *http.Request) {
+// registerREST registers the REST endpoints on mux.
+func (s *Server) registerREST(mux *http.ServeMux) {
+	mux.HandleFunc("/blocks", s.treeHandler(func(bc *core.TriadBlockchain, query url.Values) (interface{}, error) {
+		return bc.BFS(bc.Root).Blocks(queryLimit(query)), nil
+	}))
+
+	mux.HandleFunc("/head", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.state.Blockchain.ForkChoice.Head())
+	})
+
+	mux.HandleFunc("/finalized", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.state.Blockchain.Finality.FinalizedHead())
+	})
+
+	mux.HandleFunc("/chain", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		data, _ := json.Marshal(state.Blockchain.Root)
-		w.Write(data)
+		json.NewEncoder(w).Encode(s.state.Blockchain.ForkChoice.CanonicalPath())
 	})
 
-	http.HandleFunc("/validate", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/validate", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		valid := state.ValidateBlockchain()
+		valid := s.state.ValidateBlockchain()
 		json.NewEncoder(w).Encode(map[string]bool{"valid": valid})
 	})
 
-	http.ListenAndServe(":8081", nil) // Run on different port to not conflict with WebSocket
+	mux.HandleFunc("/proof", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		txHash := r.URL.Query().Get("tx")
+		blockHash, err := s.state.Store.GetTransactionBlock(txHash)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		block, err := s.state.Store.GetBlock(blockHash)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		proof, err := block.TxProof(txHash)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		// The header and signature are all a light client needs to check the proof
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"blockHash": block.Hash,
+			"header":    block.BlockHeader,
+			"signature": block.Signature,
+			"proof":     proof,
+		})
+	})
+
+	mux.HandleFunc("/transactions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		query := r.URL.Query()
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		page, err := s.state.Store.GetTransactions(query.Get("address"), query.Get("direction"), limit, query.Get("cursor"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+
+	mux.HandleFunc("/tree/walk", s.treeHandler(func(bc *core.TriadBlockchain, query url.Values) (interface{}, error) {
+		root := bc.Root
+		if hash := query.Get("hash"); hash != "" {
+			node, exists := bc.Nodes[hash]
+			if !exists {
+				return nil, fmt.Errorf("block not found: %s", hash)
+			}
+			root = node
+		}
+		switch query.Get("order") {
+		case "", "bfs":
+			return bc.BFS(root).Blocks(queryLimit(query)), nil
+		case "dfs":
+			return bc.DFS(root).Blocks(queryLimit(query)), nil
+		default:
+			return nil, fmt.Errorf("unknown order %q", query.Get("order"))
+		}
+	}))
+
+	mux.HandleFunc("/tree/range", s.treeHandler(func(bc *core.TriadBlockchain, query url.Values) (interface{}, error) {
+		from, err := strconv.Atoi(query.Get("from"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid from height: %v", err)
+		}
+		to, err := strconv.Atoi(query.Get("to"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid to height: %v", err)
+		}
+		return bc.HeightRange(from, to).Blocks(queryLimit(query)), nil
+	}))
+
+	mux.HandleFunc("/tree/ancestors", s.treeHandler(func(bc *core.TriadBlockchain, query url.Values) (interface{}, error) {
+		it, err := bc.Ancestors(query.Get("hash"))
+		if err != nil {
+			return nil, err
+		}
+		return it.Blocks(queryLimit(query)), nil
+	}))
+
+	mux.HandleFunc("/tree/descendants", s.treeHandler(func(bc *core.TriadBlockchain, query url.Values) (interface{}, error) {
+		it, err := bc.Descendants(query.Get("hash"))
+		if err != nil {
+			return nil, err
+		}
+		return it.Blocks(queryLimit(query)), nil
+	}))
+
+	mux.HandleFunc("/tree/leaves", s.treeHandler(func(bc *core.TriadBlockchain, query url.Values) (interface{}, error) {
+		return bc.Leaves().Blocks(queryLimit(query)), nil
+	}))
+
+	mux.HandleFunc("/tree/lca", s.treeHandler(func(bc *core.TriadBlockchain, query url.Values) (interface{}, error) {
+		node, err := bc.LowestCommonAncestor(query.Get("a"), query.Get("b"))
+		if err != nil {
+			return nil, err
+		}
+		return node.Block, nil
+	}))
+
+	mux.HandleFunc("/tree/path", s.treeHandler(func(bc *core.TriadBlockchain, query url.Values) (interface{}, error) {
+		return bc.Path(query.Get("from"), query.Get("to"))
+	}))
+}
+
+// treeHandler serves a triad tree query as JSON, holding the blockchain mutex while it runs.
+func (s *Server) treeHandler(query func(bc *core.TriadBlockchain, query url.Values) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		bc := s.state.Blockchain
+		bc.Mutex.Lock()
+		result, err := query(bc, r.URL.Query())
+		bc.Mutex.Unlock()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// queryLimit returns the limit query parameter, or 0 for no limit.
+func queryLimit(query url.Values) int {
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	return limit
 }
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Artfain/triad-networks/core"
+)
+
+// Server serves the WebSocket and HTTP API of a node from its single shared state.
+type Server struct {
+	state   *core.State
+	mempool *core.Mempool
+}
+
+// NewServer creates an API server over the node's state and pending transaction pool.
+func NewServer(state *core.State, mempool *core.Mempool) *Server {
+	return &Server{state: state, mempool: mempool}
+}
+
+// RegisterHandlers registers every API handler on mux.
+func (s *Server) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/nodes", s.handleNodes)
+	mux.HandleFunc("/tokens", s.handleTokens)
+	s.registerREST(mux)
+}
@@ -2,8 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 
 	"github.com/Artfain/triad-networks/core"
 	"github.com/gorilla/websocket"
@@ -23,7 +28,8 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+// handleWebSocket serves a WebSocket connection.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		slog.Error("Failed to upgrade to WebSocket", "error", err)
@@ -31,6 +37,15 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
+	// Event subscriptions write concurrently with replies, so serialize all writes
+	var writeMutex sync.Mutex
+	send := func(v interface{}) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		conn.WriteJSON(v)
+	}
+	subscribed := false
+
 	for {
 		var msg Message
 		err := conn.ReadJSON(&msg)
@@ -40,102 +55,124 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		}
 
 		switch msg.Type {
-		case "register":
-			var data struct {
-				Address  string `json:"address"`
-				DeviceID string `json:"deviceID"`
-			}
-			if err := json.Unmarshal(msg.Data, &data); err != nil {
-				conn.WriteJSON(map[string]string{"error": "invalid data"})
-				continue
-			}
-			userData := core.UserData{
-				Balance:      1000,
-				Reputation:   core.NewReputation(),
-				Devices:      []string{data.DeviceID},
-				TreesPlanted: 0,
-			}
-			if err := state.AddUser(data.Address, data.DeviceID, userData); err != nil {
-				conn.WriteJSON(map[string]string{"error": err.Error()})
+		case "register", "contribute", "add_device", "remove_device", "eco_action", "send_transaction":
+			// Every mutation is a signed transaction that takes effect once included in a block
+			var tx core.Transaction
+			if err := json.Unmarshal(msg.Data, &tx); err != nil {
+				send(map[string]string{"error": "invalid data"})
 				continue
 			}
-			conn.WriteJSON(map[string]string{"status": "registered"})
-
-		case "contribute":
-			var data struct {
-				Address      string               `json:"address"`
-				DeviceID     string               `json:"deviceID"`
-				Contribution core.PoCContribution `json:"contribution"`
-				Trees        int64                `json:"trees"`
-			}
-			if err := json.Unmarshal(msg.Data, &data); err != nil {
-				conn.WriteJSON(map[string]string{"error": "invalid data"})
+			if msg.Type != "send_transaction" && string(tx.Type) != msg.Type {
+				send(map[string]string{"error": "transaction type does not match message type"})
 				continue
 			}
-			userData, exists := state.GetData(data.Address)
-			if !exists {
-				conn.WriteJSON(map[string]string{"error": "user not found"})
+			if err := s.mempool.Add(tx); err != nil {
+				send(map[string]string{"error": err.Error()})
 				continue
 			}
-			isHonest := !core.DetectCheat(data.Contribution.Computations)
-			userData.Reputation = core.UpdateReputation(userData.Reputation, data.Contribution.Uptime, isHonest)
-			state.UpdateData(data.Address, data.DeviceID, data.Contribution, data.Trees)
-			conn.WriteJSON(map[string]string{"status": "contribution recorded"})
+			send(map[string]string{"status": "pending", "hash": tx.Hash()})
 
 		case "get_data":
 			var data struct {
 				Address string `json:"address"`
 			}
 			if err := json.Unmarshal(msg.Data, &data); err != nil {
-				conn.WriteJSON(map[string]string{"error": "invalid data"})
+				send(map[string]string{"error": "invalid data"})
 				continue
 			}
-			userData, exists := state.GetData(data.Address)
+			userData, exists := s.state.GetData(data.Address)
 			if !exists {
-				conn.WriteJSON(map[string]string{"error": "user not found"})
+				send(map[string]string{"error": "user not found"})
 				continue
 			}
-			conn.WriteJSON(userData)
+			send(userData)
 
 		case "get_transactions":
 			var data struct {
-				Address string `json:"address"`
+				Address   string `json:"address"`
+				Direction string `json:"direction"`
+				Limit     int    `json:"limit"`
+				Cursor    string `json:"cursor"`
 			}
 			if err := json.Unmarshal(msg.Data, &data); err != nil {
-				conn.WriteJSON(map[string]string{"error": "invalid data"})
+				send(map[string]string{"error": "invalid data"})
 				continue
 			}
-			transactions, err := core.GetTransactions(data.Address)
+			page, err := s.state.Store.GetTransactions(data.Address, data.Direction, data.Limit, data.Cursor)
 			if err != nil {
-				conn.WriteJSON(map[string]string{"error": err.Error()})
+				send(map[string]string{"error": err.Error()})
 				continue
 			}
-			conn.WriteJSON(transactions)
+			send(page)
+
+		case "subscribe_reorgs":
+			if !subscribed {
+				subscribed = true
+				reorgs := s.state.SubscribeReorgs()
+				defer s.state.UnsubscribeReorgs(reorgs)
+				go func() {
+					for event := range reorgs {
+						send(map[string]interface{}{"type": "reorg", "data": event})
+					}
+				}()
+			}
+			send(map[string]string{"status": "subscribed"})
 
 		case "get_trees":
 			var data struct {
 				Address string `json:"address"`
 			}
 			if err := json.Unmarshal(msg.Data, &data); err != nil {
-				conn.WriteJSON(map[string]string{"error": "invalid data"})
+				send(map[string]string{"error": "invalid data"})
 				continue
 			}
-			trees := state.GetTreesPlanted(data.Address)
-			conn.WriteJSON(map[string]int64{"treesPlanted": trees})
+			trees := s.state.GetTreesPlanted(data.Address)
+			send(map[string]int64{"treesPlanted": trees})
 		}
 	}
 }
 
-var state = core.NewState()
-
-func GetNodes(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	data, _ := json.Marshal(state.Blockchain.Nodes)
-	w.Write(data)
+// handleNodes returns the blocks of the triad tree in order of height, optionally limited to the
+// heights from and to.
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	s.treeHandler(func(bc *core.TriadBlockchain, query url.Values) (interface{}, error) {
+		from, to := 0, math.MaxInt
+		var err error
+		if value := query.Get("from"); value != "" {
+			if from, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid from height: %v", err)
+			}
+		}
+		if value := query.Get("to"); value != "" {
+			if to, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid to height: %v", err)
+			}
+		}
+		return bc.HeightRange(from, to).Blocks(queryLimit(query)), nil
+	})(w, r)
 }
 
-func GetTokens(w http.ResponseWriter, r *http.Request) {
+// handleTokens returns every user's data, or with an address query a single account together with
+// a proof against the canonical head's state root.
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	data, _ := json.Marshal(state.Users)
+	if address := r.URL.Query().Get("address"); address != "" {
+		proof, head, err := s.state.AccountProof(address)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"blockHash": head.Hash,
+			"header":    head.BlockHeader,
+			"signature": head.Signature,
+			"proof":     proof,
+		})
+		return
+	}
+	s.state.Mutex.Lock()
+	data, _ := json.Marshal(s.state.Users)
+	s.state.Mutex.Unlock()
 	w.Write(data)
 }
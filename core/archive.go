@@ -0,0 +1,82 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// BlockArchive is an append-only file of gzip-compressed block bodies. Each body is its own gzip
+// member, so it can be read back on its own from the offset and length recorded in the store.
+type BlockArchive struct {
+	file  *os.File
+	size  int64
+	mutex sync.Mutex
+}
+
+// archivedBody is the part of a block moved into the archive.
+type archivedBody struct {
+	Data     []Transaction
+	Evidence []*Evidence
+}
+
+// OpenBlockArchive opens the archive file at path, creating it if needed.
+func OpenBlockArchive(path string) (*BlockArchive, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open block archive: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat block archive: %v", err)
+	}
+	return &BlockArchive{file: file, size: info.Size()}, nil
+}
+
+// Close closes the archive file.
+func (a *BlockArchive) Close() error {
+	return a.file.Close()
+}
+
+// append compresses a block's body onto the end of the archive and returns where it was written.
+func (a *BlockArchive) append(block *Block) (int64, int64, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(archivedBody{Data: block.Data, Evidence: block.Evidence}); err != nil {
+		return 0, 0, fmt.Errorf("failed to compress block %s: %v", block.Hash, err)
+	}
+	if err := zw.Close(); err != nil {
+		return 0, 0, fmt.Errorf("failed to compress block %s: %v", block.Hash, err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	offset := a.size
+	if _, err := a.file.WriteAt(buf.Bytes(), offset); err != nil {
+		return 0, 0, fmt.Errorf("failed to archive block %s: %v", block.Hash, err)
+	}
+	if err := a.file.Sync(); err != nil {
+		return 0, 0, fmt.Errorf("failed to archive block %s: %v", block.Hash, err)
+	}
+	a.size += int64(buf.Len())
+	return offset, int64(buf.Len()), nil
+}
+
+// read decompresses the body written at offset.
+func (a *BlockArchive) read(offset, length int64) (*archivedBody, error) {
+	zr, err := gzip.NewReader(io.NewSectionReader(a.file, offset, length))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archived body: %v", err)
+	}
+	defer zr.Close()
+	var body archivedBody
+	if err := json.NewDecoder(zr).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode archived body: %v", err)
+	}
+	return &body, nil
+}
@@ -2,58 +2,107 @@ package core
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"time"
 )
 
-// Block represents a block in the triad-based blockchain.
+// BlockHeader is the part of a block covered by its hash and signature. It commits to the body
+// and the resulting account state through Merkle roots, so a transaction's inclusion or an
+// account's data can be proven against the header alone.
+type BlockHeader struct {
+	Index        int
+	Slot         uint64 // Production slot the block was proposed in
+	Timestamp    int64
+	ParentHash   string // Hash of the parent block
+	TxRoot       string // Merkle root of the block's transactions
+	EvidenceRoot string // Merkle root of the block's slashing evidence
+	StateRoot    string `json:",omitempty"` // Root of the sparse Merkle tree over all accounts after the block
+	Validator    string // Address of the validator
+
+	// Validator sets are committed by hash; the next set is only given on the last block of an epoch
+	ValidatorSetHash     string `json:",omitempty"` // Set that elected the validator
+	NextValidatorSetHash string `json:",omitempty"` // Set taking over after this block
+}
+
+// Hash returns the hex-encoded sha256 of the header.
+func (h *BlockHeader) Hash() string {
+	data, _ := json.Marshal(h)
+	hash := sha256.Sum256(data)
+	return fmt.Sprintf("%x", hash)
+}
+
+// Block represents a block in the triad-based blockchain: a header and the body it commits to.
 type Block struct {
-	Index      int
-	Timestamp  int64
-	Data       []Transaction // Transactions or PoC data
-	ParentHash string        // Hash of the parent block
-	Hash       string        // Hash of this block
-	Children   [3]string     // Hashes of up to 3 child blocks
-	Validator  string        // Address of the validator
-	Signature  string        // Signature of the block
+	BlockHeader
+	Data      []Transaction // Transactions or PoC data
+	Evidence  []*Evidence   // Slashing evidence against misbehaving validators
+	Hash      string        // Hash of the header
+	Signature string        // Signature of the block
+	Archived  bool          `json:",omitempty"` // The body was moved to the block archive and is not held
 }
 
-func NewBlock(index int, data []Transaction, parentHash string, validator string) *Block {
+func NewBlock(index int, slot uint64, data []Transaction, parentHash string, validator string) *Block {
 	b := &Block{
-		Index:      index,
-		Timestamp:  time.Now().UnixNano(),
-		Data:       data,
-		ParentHash: parentHash,
-		Children:   [3]string{},
-		Validator:  validator,
+		BlockHeader: BlockHeader{
+			Index:      index,
+			Slot:       slot,
+			Timestamp:  time.Now().UnixNano(),
+			ParentHash: parentHash,
+			Validator:  validator,
+		},
+		Data: data,
 	}
-	b.Hash = b.calculateHash()
+	b.seal()
 	return b
 }
 
 // calculateHash calculates the hash of the block.
 func (b *Block) calculateHash() string {
-	data, _ := json.Marshal(struct {
-		Index      int
-		Timestamp  int64
-		Data       []Transaction
-		ParentHash string
-		Children   [3]string
-		Validator  string
-	}{
-		Index:      b.Index,
-		Timestamp:  b.Timestamp,
-		Data:       b.Data,
-		ParentHash: b.ParentHash,
-		Children:   b.Children,
-		Validator:  b.Validator,
-	})
-	hash := sha256.Sum256(data)
-	return fmt.Sprintf("%x", hash)
+	return b.BlockHeader.Hash()
 }
 
-// SignBlock signs the block with the validator's private key (simplified, use actual ECDSA in production).
-func (b *Block) SignBlock(privateKey string) {
-	b.Signature = "signed_" + b.Hash // Simplified signature, replace with real ECDSA
+// seal commits the header to the current body and recomputes the block hash.
+func (b *Block) seal() {
+	b.TxRoot = TxRoot(b.Data)
+	b.EvidenceRoot = evidenceRoot(b.Evidence)
+	b.Hash = b.calculateHash()
+}
+
+// verifyBody reports whether the body matches the Merkle roots in the header.
+func (b *Block) verifyBody() bool {
+	return b.TxRoot == TxRoot(b.Data) && b.EvidenceRoot == evidenceRoot(b.Evidence)
+}
+
+// SignBlock signs the block hash with the validator's hex-encoded secp256k1 private key.
+func (b *Block) SignBlock(privateKey string) error {
+	hash, err := hex.DecodeString(b.Hash)
+	if err != nil {
+		return fmt.Errorf("invalid block hash: %v", err)
+	}
+	sig, err := signHash(privateKey, hash)
+	if err != nil {
+		return fmt.Errorf("failed to sign block: %v", err)
+	}
+	b.Signature = sig
+	return nil
+}
+
+// VerifySignature checks that the block hash and body are intact and signed by the given public key.
+func (b *Block) VerifySignature(pubKey string) bool {
+	if b.Hash != b.calculateHash() || !b.verifyBody() {
+		return false
+	}
+	return b.signedBy(pubKey)
+}
+
+// signedBy reports whether the block hash is signed by the given public key, without checking the
+// hash against the header or the body.
+func (b *Block) signedBy(pubKey string) bool {
+	hash, err := hex.DecodeString(b.Hash)
+	if err != nil {
+		return false
+	}
+	return verifyHash(pubKey, hash, b.Signature)
 }
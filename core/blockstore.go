@@ -0,0 +1,332 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Block store key layout:
+//
+//	block:<hash>                 -> JSON-encoded block
+//	height:<height>:<hash>       -> hash (height zero-padded so keys sort numerically)
+//	parent:<parentHash>:<hash>   -> hash
+//	archive:<hash>               -> offset:length of the block's body in the block archive
+//	genesis:<height>:<address>   -> JSON-encoded genesis validator
+//	finalized                    -> hash of the finalized head
+//	emission                     -> JSON-encoded emission schedule the chain was created with
+//	lastvote:<address>:<type>    -> height of the validator's last vote of that type
+func blockKey(hash string) []byte {
+	return []byte("block:" + hash)
+}
+
+func heightKey(index int, hash string) []byte {
+	return []byte(fmt.Sprintf("height:%020d:%s", index, hash))
+}
+
+func parentKey(parentHash, hash string) []byte {
+	return []byte(fmt.Sprintf("parent:%s:%s", parentHash, hash))
+}
+
+func archiveKey(hash string) []byte {
+	return []byte("archive:" + hash)
+}
+
+func genesisKey(v GenesisValidator) []byte {
+	return []byte(fmt.Sprintf("genesis:%020d:%s", v.Height, v.Address))
+}
+
+var finalizedKey = []byte("finalized")
+
+func lastVoteKey(address string, voteType VoteType) []byte {
+	return []byte(fmt.Sprintf("lastvote:%s:%s", address, voteType))
+}
+
+// StoreFinalized records the hash of the finalized head.
+func (st *Store) StoreFinalized(hash string) error {
+	if err := st.kv.Put(finalizedKey, []byte(hash)); err != nil {
+		return fmt.Errorf("failed to store finalized head: %v", err)
+	}
+	return nil
+}
+
+// GetFinalized retrieves the hash of the finalized head, or "" if nothing beyond genesis was finalized.
+func (st *Store) GetFinalized() (string, error) {
+	hash, err := st.kv.Get(finalizedKey)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get finalized head: %v", err)
+	}
+	return string(hash), nil
+}
+
+// StoreLastVote records the height of a validator's last vote of voteType.
+func (st *Store) StoreLastVote(address string, voteType VoteType, height int) error {
+	if err := st.kv.Put(lastVoteKey(address, voteType), []byte(strconv.Itoa(height))); err != nil {
+		return fmt.Errorf("failed to store last vote: %v", err)
+	}
+	return nil
+}
+
+// GetLastVote retrieves the height of a validator's last vote of voteType, or 0 if it never voted.
+func (st *Store) GetLastVote(address string, voteType VoteType) (int, error) {
+	value, err := st.kv.Get(lastVoteKey(address, voteType))
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last vote: %v", err)
+	}
+	height, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse last vote: %v", err)
+	}
+	return height, nil
+}
+
+var emissionKey = []byte("emission")
+
+// StoreEmission records the emission schedule the chain was created with.
+func (st *Store) StoreEmission(es EmissionSchedule) error {
+	dataBytes, err := json.Marshal(es)
+	if err != nil {
+		return fmt.Errorf("failed to marshal emission schedule: %v", err)
+	}
+	if err := st.kv.Put(emissionKey, dataBytes); err != nil {
+		return fmt.Errorf("failed to store emission schedule: %v", err)
+	}
+	return nil
+}
+
+// GetEmission retrieves the emission schedule the chain was created with, reporting false if none
+// was stored yet.
+func (st *Store) GetEmission() (EmissionSchedule, bool, error) {
+	var es EmissionSchedule
+	dataBytes, err := st.kv.Get(emissionKey)
+	if errors.Is(err, ErrNotFound) {
+		return es, false, nil
+	}
+	if err != nil {
+		return es, false, fmt.Errorf("failed to get emission schedule: %v", err)
+	}
+	if err := json.Unmarshal(dataBytes, &es); err != nil {
+		return es, false, fmt.Errorf("failed to unmarshal emission schedule: %v", err)
+	}
+	return es, true, nil
+}
+
+// StoreGenesisValidator stores a validator bonded outside any block.
+func (st *Store) StoreGenesisValidator(v GenesisValidator) error {
+	dataBytes, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal genesis validator: %v", err)
+	}
+	if err := st.kv.Put(genesisKey(v), dataBytes); err != nil {
+		return fmt.Errorf("failed to store genesis validator: %v", err)
+	}
+	return nil
+}
+
+// GenesisValidators retrieves all stored genesis validators ordered by the height they were added at.
+func (st *Store) GenesisValidators() ([]GenesisValidator, error) {
+	var validators []GenesisValidator
+	var decodeErr error
+	err := st.kv.IteratePrefix([]byte("genesis:"), nil, func(key, value []byte) bool {
+		var v GenesisValidator
+		if err := json.Unmarshal(value, &v); err != nil {
+			decodeErr = fmt.Errorf("failed to unmarshal genesis validator: %v", err)
+			return false
+		}
+		validators = append(validators, v)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterator error: %v", err)
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return validators, nil
+}
+
+// StoreBlock stores a block and indexes it by height and parent.
+func (st *Store) StoreBlock(block *Block) error {
+	dataBytes, err := json.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block: %v", err)
+	}
+	batch := st.NewBatch()
+	batch.Put(blockKey(block.Hash), dataBytes)
+	batch.Put(heightKey(block.Index, block.Hash), []byte(block.Hash))
+	batch.Put(parentKey(block.ParentHash, block.Hash), []byte(block.Hash))
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to store block: %v", err)
+	}
+	return nil
+}
+
+// DeleteBlock removes a block and its index entries.
+func (st *Store) DeleteBlock(block *Block) error {
+	batch := st.NewBatch()
+	batch.Delete(blockKey(block.Hash))
+	batch.Delete(heightKey(block.Index, block.Hash))
+	batch.Delete(parentKey(block.ParentHash, block.Hash))
+	batch.Delete(archiveKey(block.Hash))
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to delete block: %v", err)
+	}
+	return nil
+}
+
+// ArchiveBlock moves a stored block's body into the block archive, keeping only its header in the
+// store. The block itself is left untouched.
+func (st *Store) ArchiveBlock(block *Block) error {
+	if st.archive == nil {
+		return fmt.Errorf("no block archive open")
+	}
+	offset, length, err := st.archive.append(block)
+	if err != nil {
+		return err
+	}
+	header := &Block{BlockHeader: block.BlockHeader, Hash: block.Hash, Signature: block.Signature, Archived: true}
+	dataBytes, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block: %v", err)
+	}
+	batch := st.NewBatch()
+	batch.Put(blockKey(block.Hash), dataBytes)
+	batch.Put(archiveKey(block.Hash), []byte(fmt.Sprintf("%d:%d", offset, length)))
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to archive block: %v", err)
+	}
+	return nil
+}
+
+// GetBlock retrieves a block by hash, restoring its body from the block archive if it was archived.
+func (st *Store) GetBlock(hash string) (*Block, error) {
+	block, err := st.getStoredBlock(hash)
+	if err != nil || !block.Archived {
+		return block, err
+	}
+	if st.archive == nil {
+		return nil, fmt.Errorf("block %s is archived but no block archive is open", hash)
+	}
+	location, err := st.kv.Get(archiveKey(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archive location of block %s: %v", hash, err)
+	}
+	var offset, length int64
+	if _, err := fmt.Sscanf(string(location), "%d:%d", &offset, &length); err != nil {
+		return nil, fmt.Errorf("invalid archive location of block %s: %v", hash, err)
+	}
+	body, err := st.archive.read(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	block.Data, block.Evidence, block.Archived = body.Data, body.Evidence, false
+	return block, nil
+}
+
+// getStoredBlock retrieves a block as stored, which for archived blocks is only the header.
+func (st *Store) getStoredBlock(hash string) (*Block, error) {
+	dataBytes, err := st.kv.Get(blockKey(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %v", hash, err)
+	}
+	var block Block
+	if err := json.Unmarshal(dataBytes, &block); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal block %s: %v", hash, err)
+	}
+	return &block, nil
+}
+
+// GetChildHashes retrieves the hashes of all stored children of a block.
+func (st *Store) GetChildHashes(parentHash string) ([]string, error) {
+	var hashes []string
+	err := st.kv.IteratePrefix([]byte("parent:"+parentHash+":"), nil, func(key, value []byte) bool {
+		hashes = append(hashes, string(value))
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterator error: %v", err)
+	}
+	return hashes, nil
+}
+
+// LoadBlocks retrieves all stored blocks ordered by height. Archived blocks come back without their bodies.
+func (st *Store) LoadBlocks() ([]*Block, error) {
+	var blocks []*Block
+	var blockErr error
+	err := st.kv.IteratePrefix([]byte("height:"), nil, func(key, value []byte) bool {
+		block, err := st.getStoredBlock(string(value))
+		if err != nil {
+			blockErr = err
+			return false
+		}
+		blocks = append(blocks, block)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterator error: %v", err)
+	}
+	if blockErr != nil {
+		return nil, blockErr
+	}
+	return blocks, nil
+}
+
+// LoadTriadBlockchain rebuilds the triad tree from the block store, storing a new genesis block if
+// it is empty. The finalized head is restored before the fork choice runs, so it never selects a
+// branch conflicting with a block finalized before the restart.
+func LoadTriadBlockchain(store *Store) (*TriadBlockchain, error) {
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		bc := NewTriadBlockchain()
+		if err := store.StoreBlock(bc.Root.Block); err != nil {
+			return nil, err
+		}
+		return bc, nil
+	}
+
+	genesis := blocks[0]
+	if genesis.Index != 0 {
+		return nil, fmt.Errorf("stored chain has no genesis block")
+	}
+	if genesis.ParentHash != "0" || genesis.Hash != genesis.calculateHash() || !genesis.verifyBody() {
+		return nil, fmt.Errorf("stored genesis block %s is corrupted", genesis.Hash)
+	}
+	bc := newTriadBlockchain(genesis)
+	// Validator keys are only known again once NewState replays the canonical chain, which checks
+	// the signatures of the blocks on it
+	for _, block := range blocks[1:] {
+		parentNode, exists := bc.Nodes[block.ParentHash]
+		if !exists {
+			return nil, fmt.Errorf("parent block not found: %s", block.ParentHash)
+		}
+		if err := bc.validateBlock(parentNode.Block, block, false); err != nil {
+			return nil, fmt.Errorf("stored block %s is corrupted: %v", block.Hash, err)
+		}
+		if _, err := bc.attach(block); err != nil {
+			return nil, err
+		}
+	}
+	finalized, err := store.GetFinalized()
+	if err != nil {
+		return nil, err
+	}
+	if finalized != "" {
+		node, exists := bc.Nodes[finalized]
+		if !exists {
+			return nil, fmt.Errorf("finalized block %s not found", finalized)
+		}
+		bc.Finality.finalize(node.Block)
+	}
+	bc.ForkChoice.Update(bc)
+	return bc, nil
+}
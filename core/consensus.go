@@ -1,84 +1,164 @@
 package core
 
 import (
-	"math/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
 	"sync"
 )
 
+// scoreScale is the fixed-point scale of reputation scores in selection weights.
+const scoreScale = 1e6
+
 // Consensus manages the PoS + PoC consensus mechanism.
 type Consensus struct {
-	validators map[string]int64       // Address -> Stake (balance)
-	reputation map[string]*Reputation // Address -> Reputation
+	set        *ValidatorSet            // Validator set of the current epoch
+	validators map[string]ValidatorInfo // Address -> entry in set
+	keys       map[string]string        // Address -> Public key
+	jailed     map[string]struct{}      // Slashed validators without weight or stake until released
 	mutex      sync.Mutex
 }
 
-// NewConsensus creates a new consensus instance.
+// NewConsensus creates a new consensus instance with an empty validator set.
 func NewConsensus() *Consensus {
 	return &Consensus{
-		validators: make(map[string]int64),
-		reputation: make(map[string]*Reputation),
+		set:        &ValidatorSet{},
+		validators: make(map[string]ValidatorInfo),
+		keys:       make(map[string]string),
+		jailed:     make(map[string]struct{}),
 	}
 }
 
-// AddValidator adds a validator with stake and reputation.
-func (c *Consensus) AddValidator(address string, stake int64, rep *Reputation) {
+// SetValidators replaces the active validator set, typically at an epoch boundary.
+func (c *Consensus) SetValidators(set *ValidatorSet) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	c.validators[address] = stake
-	c.reputation[address] = rep
+	c.set = set
+	c.validators = make(map[string]ValidatorInfo, len(set.Validators))
+	for _, v := range set.Validators {
+		c.validators[v.Address] = v
+	}
 }
 
-// RemoveValidator removes a validator.
-func (c *Consensus) RemoveValidator(address string) {
+// ValidatorSet returns the active validator set.
+func (c *Consensus) ValidatorSet() *ValidatorSet {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	delete(c.validators, address)
-	delete(c.reputation, address)
+	return c.set
 }
 
-// SelectValidator selects a validator based on stake and reputation-weighted random selection.
-func (c *Consensus) SelectValidator() string {
+// RegisterKey registers the public key used to verify an address's block signatures.
+func (c *Consensus) RegisterKey(address, pubKey string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	c.keys[address] = pubKey
+}
 
-	if len(c.validators) == 0 {
-		return ""
+// SetJailed takes a validator out of leader selection and finality voting, or lets it back in.
+// Jailing applies at once instead of waiting for the next epoch's validator set.
+func (c *Consensus) SetJailed(address string, jailed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if jailed {
+		c.jailed[address] = struct{}{}
+	} else {
+		delete(c.jailed, address)
 	}
+}
 
-	totalWeight := float64(0)
-	for address, stake := range c.validators {
-		repScore := c.reputation[address].Score
-		totalWeight += float64(stake) * repScore
+// PublicKey returns the registered public key of an address.
+func (c *Consensus) PublicKey(address string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	pubKey, exists := c.keys[address]
+	return pubKey, exists
+}
+
+// SelectValidator deterministically selects the leader for a slot on top of a parent block.
+// Validators of the active set are walked in address order and weighted by stake × reputation
+// score; the draw is seeded from the parent hash and slot, so every node with the same validator
+// set agrees on the leader and anyone can recompute it afterwards.
+func (c *Consensus) SelectValidator(parentHash string, slot uint64) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	totalWeight := uint64(0)
+	for _, v := range c.set.Validators {
+		totalWeight += uint64(c.weight(v.Address))
 	}
 
 	if totalWeight == 0 {
 		return ""
 	}
 
-	randNum := rand.Float64()
-	randNum *= totalWeight
-	currentWeight := float64(0)
-	for address, stake := range c.validators {
-		repScore := c.reputation[address].Score
-		currentWeight += float64(stake) * repScore
-		if randNum < currentWeight {
-			return address
+	draw := leaderSeed(parentHash, slot) % totalWeight
+	currentWeight := uint64(0)
+	for _, v := range c.set.Validators {
+		currentWeight += uint64(c.weight(v.Address))
+		if draw < currentWeight {
+			return v.Address
 		}
 	}
 	return ""
 }
 
+// weight returns a validator's selection weight; the caller must hold the mutex. Weights are
+// integers so every node computes the same leader: float sums of products may be fused into
+// multiply-adds differently on each architecture.
+func (c *Consensus) weight(address string) int64 {
+	return c.stake(address) * int64(c.validators[address].Score*scoreScale)
+}
+
+// stake returns a validator's stake, or zero if it is unknown or jailed; the caller must hold the mutex.
+func (c *Consensus) stake(address string) int64 {
+	if _, jailed := c.jailed[address]; jailed {
+		return 0
+	}
+	return c.validators[address].Stake
+}
+
+// leaderSeed derives a uniform 64-bit draw from a parent hash and slot.
+func leaderSeed(parentHash string, slot uint64) uint64 {
+	seed := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", parentHash, slot)))
+	return binary.BigEndian.Uint64(seed[:8])
+}
+
 // ValidatePoC validates the Proof-of-Contribution.
 func (c *Consensus) ValidatePoC(address string, contribution PoCContribution) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	rep, exists := c.reputation[address]
+	v, exists := c.validators[address]
 	if !exists {
 		return false
 	}
 	// Simple PoC validation based on contribution and reputation
-	if contribution.Computations > 0 && rep.Score > 0.5 {
+	if contribution.Computations > 0 && v.Score > 0.5 {
 		return true
 	}
 	return false
 }
+
+// Weight returns a validator's stake × scaled reputation weight, or zero for unknown or jailed addresses.
+func (c *Consensus) Weight(address string) int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.weight(address)
+}
+
+// Stake returns a validator's stake, or zero for unknown or jailed addresses.
+func (c *Consensus) Stake(address string) int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stake(address)
+}
+
+// TotalStake returns the combined stake of all validators that are not jailed.
+func (c *Consensus) TotalStake() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	total := int64(0)
+	for _, v := range c.set.Validators {
+		total += c.stake(v.Address)
+	}
+	return total
+}
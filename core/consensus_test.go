@@ -0,0 +1,20 @@
+package core
+
+import "testing"
+
+func TestJailedValidatorLosesWeight(t *testing.T) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	consensus := s.Blockchain.Consensus
+	if consensus.Stake(validator.addr) != minStake {
+		t.Fatalf("stake %d, want %d", consensus.Stake(validator.addr), minStake)
+	}
+	consensus.SetJailed(validator.addr, true)
+	if consensus.Stake(validator.addr) != 0 || consensus.TotalStake() != 0 || consensus.Weight(validator.addr) != 0 {
+		t.Fatal("jailed validator still has stake or weight")
+	}
+	consensus.SetJailed(validator.addr, false)
+	if consensus.Stake(validator.addr) != minStake {
+		t.Fatal("released validator did not regain its stake")
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// GenerateKeyPair generates a new secp256k1 key pair and returns the hex-encoded private and public keys.
+func GenerateKeyPair() (string, string, error) {
+	priv, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %v", err)
+	}
+	return hex.EncodeToString(priv.Serialize()), encodePublicKey(priv.PubKey()), nil
+}
+
+// PublicKeyFromPrivate derives the hex-encoded public key for a hex-encoded private key.
+func PublicKeyFromPrivate(privateKey string) (string, error) {
+	priv, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return encodePublicKey(priv.PubKey()), nil
+}
+
+// encodePublicKey encodes a public key as hex of the raw 64-byte X||Y form used by the Python clients.
+func encodePublicKey(pub *secp256k1.PublicKey) string {
+	return hex.EncodeToString(pub.SerializeUncompressed()[1:])
+}
+
+// parsePrivateKey decodes a hex-encoded 32-byte private key.
+func parsePrivateKey(privateKey string) (*secp256k1.PrivateKey, error) {
+	keyBytes, err := hex.DecodeString(privateKey)
+	if err != nil || len(keyBytes) != 32 {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	return secp256k1.PrivKeyFromBytes(keyBytes), nil
+}
+
+// parsePublicKey decodes a hex-encoded public key in raw X||Y, compressed or uncompressed form.
+func parsePublicKey(publicKey string) (*secp256k1.PublicKey, error) {
+	keyBytes, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	if len(keyBytes) == 64 {
+		keyBytes = append([]byte{0x04}, keyBytes...)
+	}
+	pub, err := secp256k1.ParsePubKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	return pub, nil
+}
+
+// signHash signs a digest and returns the base64-encoded 64-byte r||s signature.
+func signHash(privateKey string, hash []byte) (string, error) {
+	priv, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	sig := ecdsa.Sign(priv, hash)
+	r, s := sig.R(), sig.S()
+	var raw [64]byte
+	r.PutBytesUnchecked(raw[:32])
+	s.PutBytesUnchecked(raw[32:])
+	return base64.StdEncoding.EncodeToString(raw[:]), nil
+}
+
+// verifyHash checks a base64-encoded r||s signature of a digest against a hex-encoded public key.
+func verifyHash(publicKey string, hash []byte, signature string) bool {
+	pub, err := parsePublicKey(publicKey)
+	if err != nil {
+		return false
+	}
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(raw) != 64 {
+		return false
+	}
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(raw[:32]) || s.SetByteSlice(raw[32:]) {
+		return false
+	}
+	return ecdsa.NewSignature(&r, &s).Verify(hash, pub)
+}
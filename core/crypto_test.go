@@ -0,0 +1,84 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAddBlockRejectsForgedSignature(t *testing.T) {
+	tests := []struct {
+		name  string
+		forge func(t *testing.T, block, other *Block, intruder testKey)
+	}{
+		{name: "signed by another key", forge: func(t *testing.T, block, other *Block, intruder testKey) {
+			if err := block.SignBlock(intruder.priv); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{name: "signature of another block", forge: func(t *testing.T, block, other *Block, intruder testKey) {
+			block.Signature = other.Signature
+		}},
+		{name: "malformed signature", forge: func(t *testing.T, block, other *Block, intruder testKey) {
+			block.Signature = "signed_" + block.Hash
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := newTestKey(t)
+			s := newTestState(t, validator)
+			block := produce(t, openTestState(t, copyStore(t, s.Store)), validator, 1)
+			other := produce(t, openTestState(t, copyStore(t, s.Store)), validator, 1, registerTx(t, newTestKey(t)))
+
+			tt.forge(t, block, other, newTestKey(t))
+			setSlot(s, block.Slot)
+			if err := s.AddBlock(block); err == nil || !strings.Contains(err.Error(), "invalid signature") {
+				t.Fatalf("error %v, want a rejected signature", err)
+			}
+			if _, exists := s.Blockchain.Nodes[block.Hash]; exists {
+				t.Fatal("forged block was added to the tree")
+			}
+		})
+	}
+}
+
+func TestVerifyTransactionRejectsForgedSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		forge   func(t *testing.T, tx *Transaction, intruder testKey)
+		wantErr string
+	}{
+		{name: "signed by another key", forge: func(t *testing.T, tx *Transaction, intruder testKey) {
+			tx.Signature = signedTx(t, intruder, *tx).Signature
+		}, wantErr: "signature does not verify"},
+		{name: "altered after signing", forge: func(t *testing.T, tx *Transaction, intruder testKey) {
+			tx.Amount++
+		}, wantErr: "signature does not verify"},
+		{name: "public key of another address", forge: func(t *testing.T, tx *Transaction, intruder testKey) {
+			forged := signedTx(t, intruder, *tx)
+			tx.PublicKey, tx.Signature = forged.PublicKey, forged.Signature
+		}, wantErr: "does not match sender address"},
+		{name: "missing signature", forge: func(t *testing.T, tx *Transaction, intruder testKey) {
+			tx.Signature = ""
+		}, wantErr: "missing public key or signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, validator, recipient := transferTestState(t)
+			tx := signedTx(t, validator, Transaction{To: recipient.addr, Amount: 5, Fee: 1, Nonce: 1})
+			tt.forge(t, &tx, newTestKey(t))
+
+			err := s.VerifyTransaction(tx)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+			var sigErr *SignatureError
+			if !errors.As(err, &sigErr) || sigErr.From != validator.addr {
+				t.Fatalf("error %v is not a signature error for %s", err, validator.addr)
+			}
+			if block := produce(t, s, validator, 5, tx); len(block.Data) != 0 {
+				t.Fatal("forged transaction was included in a block")
+			}
+		})
+	}
+}
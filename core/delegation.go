@@ -0,0 +1,160 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	TxDelegate      TxType = "delegate"       // Delegates Amount of the sender's balance to the validator To
+	TxUndelegate    TxType = "undelegate"     // Starts unbonding Amount of the sender's delegation to To
+	TxSetCommission TxType = "set_commission" // Sets the sender's commission to Amount percent of its block rewards
+)
+
+// applyDelegation applies a delegate, undelegate or set_commission transaction.
+func (e *execution) applyDelegation(user UserData, tx Transaction) error {
+	if tx.Type == TxSetCommission {
+		user.Commission = tx.Amount
+		e.updates[tx.From] = user
+		return nil
+	}
+	if tx.To == tx.From {
+		return fmt.Errorf("cannot delegate to self")
+	}
+	validator, exists := e.account(tx.To)
+	if !exists {
+		return fmt.Errorf("validator %s not found", tx.To)
+	}
+
+	user = user.clone()
+	if user.Delegations == nil {
+		user.Delegations = make(map[string]int64, 1)
+	}
+	switch tx.Type {
+	case TxDelegate:
+		if validator.Bonded < minStake {
+			return fmt.Errorf("%s is not a bonded validator", tx.To)
+		}
+		user.Balance -= tx.Amount
+		user.Delegations[tx.To] += tx.Amount
+		validator.Delegated += tx.Amount
+	case TxUndelegate:
+		user.Delegations[tx.To] -= tx.Amount
+		if user.Delegations[tx.To] == 0 {
+			delete(user.Delegations, tx.To)
+		}
+		validator.Delegated -= tx.Amount
+		user.Unbonding = append(user.Unbonding, Unbonding{
+			Amount:        tx.Amount,
+			ReleaseHeight: e.height + unbondingBlocks,
+			Validator:     tx.To,
+		})
+	default:
+		return fmt.Errorf("unknown transaction type %q", tx.Type)
+	}
+	e.updates[tx.From] = user
+	e.updates[tx.To] = validator
+	return nil
+}
+
+// delegators returns the addresses with stake delegated to or unbonding from validator, in address
+// order. Accounts changed earlier in this execution are not indexed yet and are checked directly.
+func (e *execution) delegators(validator string) []string {
+	candidates := make(map[string]struct{}, len(e.state.delegatorIndex[validator])+len(e.updates))
+	for address := range e.state.delegatorIndex[validator] {
+		candidates[address] = struct{}{}
+	}
+	for address := range e.updates {
+		candidates[address] = struct{}{}
+	}
+	var addresses []string
+	for address := range candidates {
+		if user, _ := e.account(address); user.stakesWith(validator) {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// stakesWith reports whether the account has stake delegated to or unbonding from validator.
+func (u UserData) stakesWith(validator string) bool {
+	if u.Delegations[validator] > 0 {
+		return true
+	}
+	for _, entry := range u.Unbonding {
+		if entry.Validator == validator {
+			return true
+		}
+	}
+	return false
+}
+
+// stakedValidators returns the validators an account has stake delegated to or unbonding from.
+func (u UserData) stakedValidators() map[string]struct{} {
+	validators := make(map[string]struct{}, len(u.Delegations))
+	for validator, amount := range u.Delegations {
+		if amount > 0 {
+			validators[validator] = struct{}{}
+		}
+	}
+	for _, entry := range u.Unbonding {
+		if entry.Validator != "" {
+			validators[entry.Validator] = struct{}{}
+		}
+	}
+	return validators
+}
+
+// indexDelegationsLocked moves an account's entries in the delegator index from the validators
+// it staked with in prev to those it stakes with in next; either may be nil.
+// The caller must hold the state mutex.
+func (s *State) indexDelegationsLocked(address string, prev, next *UserData) {
+	var before, after map[string]struct{}
+	if prev != nil {
+		before = prev.stakedValidators()
+	}
+	if next != nil {
+		after = next.stakedValidators()
+	}
+	for validator := range before {
+		if _, kept := after[validator]; !kept {
+			delete(s.delegatorIndex[validator], address)
+			if len(s.delegatorIndex[validator]) == 0 {
+				delete(s.delegatorIndex, validator)
+			}
+		}
+	}
+	for validator := range after {
+		if s.delegatorIndex[validator] == nil {
+			s.delegatorIndex[validator] = make(map[string]struct{})
+		}
+		s.delegatorIndex[validator][address] = struct{}{}
+	}
+}
+
+// reward pays a block reward to a validator. The validator keeps its commission; the rest is split
+// pro rata over its own bonded stake and each delegation, with rounding going to the validator.
+func (e *execution) reward(address string, amount int64) {
+	validator, exists := e.account(address)
+	if !exists {
+		return
+	}
+	total := validator.Bonded + validator.Delegated
+	pool := amount - amount*validator.Commission/100
+	distributed := int64(0)
+	if total > 0 {
+		for _, delegator := range e.delegators(address) {
+			user, _ := e.account(delegator)
+			if user.Delegations[address] == 0 {
+				continue
+			}
+			share := pool * user.Delegations[address] / total
+			user.Balance += share
+			e.updates[delegator] = user
+			distributed += share
+		}
+	}
+	validator.Balance += amount - distributed
+	e.updates[address] = validator
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"math/big"
+	"sort"
+)
+
+// EmissionSchedule controls how many tokens each block mints, how they are shared between the
+// block producer and the PoC reward pool, and how much of the transaction fees is burned.
+type EmissionSchedule struct {
+	InitialReward   int64 // Tokens minted by the first block
+	HalvingInterval int   // Blocks after which the reward halves; 0 disables halving
+	DecayPercent    int64 // Percent the reward shrinks each epoch when halving is disabled
+	MaxSupply       int64 // Cap on the total tokens the schedule mints; 0 for no cap
+	PoCPercent      int64 // Percent of each block's emission paid into the PoC reward pool
+	FeeBurnPercent  int64 // Percent of each block's fees burned instead of paid to its validator
+}
+
+// DefaultEmissionSchedule returns the schedule used unless the node is configured otherwise.
+func DefaultEmissionSchedule() EmissionSchedule {
+	return EmissionSchedule{
+		InitialReward:   10,
+		HalvingInterval: 100000,
+		MaxSupply:       21000000,
+		PoCPercent:      20,
+		FeeBurnPercent:  50,
+	}
+}
+
+// Reward returns the emission of the block at height, given the supply minted before it.
+func (es EmissionSchedule) Reward(height int, minted int64) int64 {
+	reward := es.InitialReward
+	if es.HalvingInterval > 0 {
+		halvings := (height - 1) / es.HalvingInterval
+		if halvings >= 63 {
+			return 0
+		}
+		reward >>= uint(halvings)
+	} else if es.DecayPercent > 0 {
+		for epoch := EpochOf(height); epoch > 0 && reward > 0; epoch-- {
+			reward -= reward * es.DecayPercent / 100
+		}
+	}
+	if es.MaxSupply > 0 && minted+reward > es.MaxSupply {
+		reward = es.MaxSupply - minted
+	}
+	if reward < 0 {
+		return 0
+	}
+	return reward
+}
+
+// mint pays the block's emission to its validator and delegators, setting aside the PoC share.
+func (e *execution) mint(validator string) {
+	if _, exists := e.account(validator); !exists {
+		return
+	}
+	emission := e.state.Emission.Reward(e.height, e.minted)
+	poc := emission * e.state.Emission.PoCPercent / 100
+	e.minted += emission
+	e.pocPool += poc
+	e.reward(validator, emission-poc)
+}
+
+// maxEcoActions caps the eco actions one contribution may claim; nothing verifies them, so larger
+// claims are not paid at all.
+const maxEcoActions = 1000
+
+// verifiedPoC reports whether an account's PoC contribution qualifies for the reward pool: it must
+// not be flagged by DetectCheat, must not claim more than maxEcoActions, and the contributor's
+// reputation must be in good standing.
+func verifiedPoC(user UserData) bool {
+	c := user.PoCContribution
+	if c.Computations == 0 && c.EcoActions == 0 || DetectCheat(c.Computations) || c.EcoActions > maxEcoActions {
+		return false
+	}
+	return user.Reputation != nil && user.Reputation.Score > 0.5
+}
+
+// pocWeight returns a verified contribution's share weight: its QLI score scaled by 1000, computed
+// in integers so that every node pays out the same amounts.
+func pocWeight(c PoCContribution) int64 {
+	return int64(c.Computations + c.EcoActions*100)
+}
+
+// distributePoC pays out the PoC reward pool in proportion to each verified contribution's QLI
+// score and clears the contributions it considered. The pool carries over if nobody qualifies;
+// rounding leftovers stay in it.
+func (e *execution) distributePoC() {
+	var addresses []string
+	for address := range e.state.Users {
+		if user, _ := e.account(address); user.PoCContribution != (PoCContribution{}) {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+
+	weights := make(map[string]int64, len(addresses))
+	total := int64(0)
+	for _, address := range addresses {
+		user, _ := e.account(address)
+		if verifiedPoC(user) {
+			weights[address] = pocWeight(user.PoCContribution)
+			total += weights[address]
+		}
+	}
+
+	pool := big.NewInt(e.pocPool)
+	for _, address := range addresses {
+		user, _ := e.account(address)
+		if total > 0 {
+			// pool * weight can exceed int64 even though the share cannot
+			share := new(big.Int).Mul(pool, big.NewInt(weights[address]))
+			share.Quo(share, big.NewInt(total))
+			user.Balance += share.Int64()
+			e.pocPool -= share.Int64()
+		}
+		user.PoCContribution = PoCContribution{}
+		e.updates[address] = user
+	}
+}
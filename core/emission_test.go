@@ -0,0 +1,113 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEmissionReward(t *testing.T) {
+	halving := EmissionSchedule{InitialReward: 100, HalvingInterval: 10}
+	decay := EmissionSchedule{InitialReward: 100, DecayPercent: 10}
+	capped := EmissionSchedule{InitialReward: 100, HalvingInterval: 10, MaxSupply: 1000}
+	tests := []struct {
+		name     string
+		schedule EmissionSchedule
+		height   int
+		minted   int64
+		want     int64
+	}{
+		{name: "flat", schedule: EmissionSchedule{InitialReward: 100}, height: 1000, want: 100},
+		{name: "first block", schedule: halving, height: 1, want: 100},
+		{name: "last block before halving", schedule: halving, height: 10, want: 100},
+		{name: "first halving", schedule: halving, height: 11, want: 50},
+		{name: "second halving", schedule: halving, height: 21, want: 25},
+		{name: "halved to nothing", schedule: halving, height: 63*10 + 1, want: 0},
+		{name: "first epoch", schedule: decay, height: epochLength, want: 100},
+		{name: "one epoch of decay", schedule: decay, height: epochLength + 1, want: 90},
+		{name: "three epochs of decay", schedule: decay, height: 3*epochLength + 1, want: 73},
+		{name: "below the cap", schedule: capped, height: 1, minted: 900, want: 100},
+		{name: "reaching the cap", schedule: capped, height: 11, minted: 970, want: 30},
+		{name: "at the cap", schedule: capped, height: 1, minted: 1000, want: 0},
+		{name: "past the cap", schedule: capped, height: 1, minted: 1100, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Reward(tt.height, tt.minted); got != tt.want {
+				t.Fatalf("reward %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistributePoC(t *testing.T) {
+	tests := []struct {
+		name          string
+		pool          int64
+		contributions map[string]PoCContribution
+		want          map[string]int64
+		wantLeft      int64
+	}{
+		{
+			name: "shares by weight",
+			pool: 100,
+			contributions: map[string]PoCContribution{
+				"alice": {Computations: 3000},
+				"bob":   {EcoActions: 10},
+			},
+			want: map[string]int64{"alice": 75, "bob": 25},
+		},
+		{
+			name: "rounding stays in the pool",
+			pool: 10,
+			contributions: map[string]PoCContribution{
+				"alice": {Computations: 1},
+				"bob":   {Computations: 1},
+				"carol": {Computations: 1},
+			},
+			want:     map[string]int64{"alice": 3, "bob": 3, "carol": 3},
+			wantLeft: 1,
+		},
+		{
+			name: "unverifiable eco actions",
+			pool: 100,
+			contributions: map[string]PoCContribution{
+				"alice": {Computations: 1000},
+				"bob":   {EcoActions: maxEcoActions + 1},
+			},
+			want: map[string]int64{"alice": 100, "bob": 0},
+		},
+		{
+			name: "pool times weight overflows",
+			pool: math.MaxInt64 / 2,
+			contributions: map[string]PoCContribution{
+				"alice": {Computations: 100000},
+				"bob":   {Computations: 100000},
+			},
+			want:     map[string]int64{"alice": math.MaxInt64 / 4, "bob": math.MaxInt64 / 4},
+			wantLeft: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openTestState(t, NewMemoryBackedStore())
+			for address, c := range tt.contributions {
+				s.Users[address] = UserData{Reputation: NewReputation(), PoCContribution: c}
+			}
+			e := s.newExecution(1)
+			e.pocPool = tt.pool
+			e.distributePoC()
+			for address, want := range tt.want {
+				user, _ := e.account(address)
+				if user.Balance != want {
+					t.Errorf("%s paid %d, want %d", address, user.Balance, want)
+				}
+				if user.PoCContribution != (PoCContribution{}) {
+					t.Errorf("%s contribution not cleared", address)
+				}
+			}
+			if e.pocPool != tt.wantLeft {
+				t.Errorf("pool left %d, want %d", e.pocPool, tt.wantLeft)
+			}
+		})
+	}
+}
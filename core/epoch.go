@@ -0,0 +1,101 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const epochLength = 20 // Blocks per epoch; the validator set only changes at epoch boundaries
+
+// ValidatorInfo is one validator's entry in an epoch's validator set.
+type ValidatorInfo struct {
+	Address string
+	Stake   int64   // Bonded plus delegated stake
+	Score   float64 // Reputation score at the time of the snapshot
+}
+
+// ValidatorSet is the snapshot of validators that elects leaders and votes during an epoch.
+type ValidatorSet struct {
+	Epoch      uint64
+	Validators []ValidatorInfo // Sorted by address
+}
+
+// Hash returns the hex-encoded sha256 of the validator set.
+func (vs *ValidatorSet) Hash() string {
+	data, _ := json.Marshal(vs)
+	hash := sha256.Sum256(data)
+	return fmt.Sprintf("%x", hash)
+}
+
+// EpochOf returns the epoch a block height belongs to. Epoch k spans heights k*epochLength+1 to
+// (k+1)*epochLength, and its validator set is snapshotted when the block at k*epochLength is applied.
+func EpochOf(height int) uint64 {
+	if height <= 0 {
+		return 0
+	}
+	return uint64((height - 1) / epochLength)
+}
+
+// validatorSet snapshots the validator set for epoch from the staking state as modified so far:
+// every unjailed account with at least the minimum stake bonded, weighted by its bonded and
+// delegated stake.
+func (e *execution) validatorSet(epoch uint64) *ValidatorSet {
+	set := &ValidatorSet{Epoch: epoch}
+	for address := range e.state.Users {
+		user, _ := e.account(address)
+		if user.JailedUntil > 0 || user.Bonded < minStake {
+			continue
+		}
+		score := float64(0)
+		if user.Reputation != nil {
+			score = user.Reputation.Score
+		}
+		set.Validators = append(set.Validators, ValidatorInfo{
+			Address: address,
+			Stake:   user.Bonded + user.Delegated,
+			Score:   score,
+		})
+	}
+	sort.Slice(set.Validators, func(i, j int) bool {
+		return set.Validators[i].Address < set.Validators[j].Address
+	})
+	return set
+}
+
+// endBlock mints the block's emission, pays the validator its fees and, at an epoch boundary, pays
+// out the PoC reward pool and snapshots the next validator set.
+func (e *execution) endBlock(validator string) {
+	e.mint(validator)
+	e.collectFees(validator)
+	if e.height%epochLength == 0 {
+		e.distributePoC()
+		e.validators = e.validatorSet(uint64(e.height / epochLength))
+	}
+}
+
+// branchValidatorSetHash returns the hash of the validator set in force on top of parent on its own
+// branch: the last one rotated in by an epoch boundary block or seeded at genesis on the way up.
+// The caller must hold the blockchain mutex.
+func (s *State) branchValidatorSetHash(parent *TriadNode) string {
+	it := s.Blockchain.lineage(parent)
+	for node := it.Next(); node != nil; node = it.Next() {
+		if hash, seeded := s.seededSets[node.Block.Hash]; seeded {
+			return hash
+		}
+		if node.Block.NextValidatorSetHash != "" {
+			return node.Block.NextValidatorSetHash
+		}
+	}
+	return (&ValidatorSet{}).Hash()
+}
+
+// nextValidatorSetHash returns the hash of the validator set an execution rotates in, or "" if the
+// block does not end an epoch.
+func (e *execution) nextValidatorSetHash() string {
+	if e.validators == nil {
+		return ""
+	}
+	return e.validators.Hash()
+}
@@ -0,0 +1,39 @@
+package core
+
+import "testing"
+
+func TestForkAcrossEpochBoundary(t *testing.T) {
+	validator := newTestKey(t)
+	node := newTestState(t, validator)
+	for slot := uint64(1); slot < epochLength; slot++ {
+		produce(t, node, validator, slot)
+	}
+	forkStore := copyStore(t, node.Store)
+	// The local block at the boundary rotates in the next epoch's set
+	boundary := produce(t, node, validator, epochLength)
+	if boundary.NextValidatorSetHash == "" {
+		t.Fatal("boundary block did not rotate the validator set")
+	}
+
+	// A competing branch from before the boundary is checked against its own epoch's set
+	other := openTestState(t, forkStore)
+	var blocks []*Block
+	for slot := uint64(epochLength + 1); slot <= epochLength+2; slot++ {
+		blocks = append(blocks, produce(t, other, validator, slot))
+	}
+	importBlocks(t, node, blocks)
+	assertSameState(t, node, other)
+
+	// A block claiming the rotated set on top of the pre-boundary parent is rejected
+	setSlot(node, epochLength+3)
+	block := NewBlock(epochLength, epochLength+3, nil, boundary.ParentHash, validator.addr)
+	block.Timestamp = node.now().UnixNano()
+	block.ValidatorSetHash = node.Blockchain.Consensus.ValidatorSet().Hash()
+	block.seal()
+	if err := block.SignBlock(validator.priv); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.AddBlock(block); err == nil {
+		t.Fatal("accepted a block with the validator set of another branch")
+	}
+}
@@ -0,0 +1,150 @@
+package core
+
+import "fmt"
+
+// execution applies a block's state transitions to copies of the affected accounts, so a block can
+// be checked without touching the state and then committed or discarded as a whole.
+type execution struct {
+	state      *State
+	height     int
+	updates    map[string]UserData
+	evidence   []string      // Offences slashed in this block
+	validators *ValidatorSet // Validator set rotated in at the end of an epoch
+	minted     int64         // Total supply minted by the emission schedule after this block
+	pocPool    int64         // PoC reward pool after this block
+	fees       int64         // Fees charged by the transactions applied so far
+	registered int           // Register transactions applied so far
+	tree       *smtNode      // State tree after this block, built by stateRoot
+}
+
+// newExecution starts an execution for a block at height; the caller must hold the state mutex.
+func (s *State) newExecution(height int) *execution {
+	return &execution{
+		state:   s,
+		height:  height,
+		updates: make(map[string]UserData),
+		minted:  s.minted,
+		pocPool: s.pocPool,
+	}
+}
+
+// account returns an account as modified so far in this execution.
+func (e *execution) account(address string) (UserData, bool) {
+	if user, exists := e.updates[address]; exists {
+		return user, true
+	}
+	user, exists := e.state.Users[address]
+	return user, exists
+}
+
+// maxBlockRegistrations limits the register transactions in one block. Registering costs no funds,
+// so the cap is what keeps account creation from flooding the state.
+const maxBlockRegistrations = 16
+
+// applyTransaction charges a transaction's fee and dispatches it to its kind, leaving the execution
+// unchanged if it fails.
+func (e *execution) applyTransaction(tx Transaction) error {
+	fromUser, exists := e.account(tx.From)
+	if err := verifyTransaction(fromUser, exists, tx); err != nil {
+		return err
+	}
+	kind := txKinds[tx.Type]
+	if kind.newAccount {
+		if e.registered >= maxBlockRegistrations {
+			return fmt.Errorf("block already holds %d registrations", maxBlockRegistrations)
+		}
+		// New accounts start empty: a grant would mint tokens outside the emission schedule
+		fromUser = UserData{}
+	}
+	fromUser.LastNonce = tx.Nonce
+	fromUser.Balance -= tx.Fee
+	if err := kind.apply(e, fromUser, tx); err != nil {
+		return err
+	}
+	if kind.newAccount {
+		e.registered++
+	}
+	e.fees += tx.Fee
+	return nil
+}
+
+// applyTransfer moves a transfer's amount from the sender to the recipient.
+func (e *execution) applyTransfer(fromUser UserData, tx Transaction) error {
+	if _, exists := e.account(tx.To); !exists {
+		return fmt.Errorf("recipient %s not found", tx.To)
+	}
+	fromUser.Balance -= tx.Amount
+	e.updates[tx.From] = fromUser
+	toUser, _ := e.account(tx.To)
+	toUser.Balance += tx.Amount
+	e.updates[tx.To] = toUser
+	return nil
+}
+
+// executeBlockLocked executes all state transitions of a block on top of its parent's state,
+// failing on the first invalid one. The validator must be the slot's leader in the validator set
+// of the parent's branch, which the consensus engine holds while the state is at the parent.
+// The caller must hold the state mutex.
+func (s *State) executeBlockLocked(block *Block) (*execution, error) {
+	if hash := s.Blockchain.Consensus.ValidatorSet().Hash(); block.ValidatorSetHash != hash {
+		return nil, fmt.Errorf("validator set hash %s does not match %s", block.ValidatorSetHash, hash)
+	}
+	if leader := s.Blockchain.Consensus.SelectValidator(block.ParentHash, block.Slot); block.Validator != leader {
+		return nil, fmt.Errorf("invalid validator %s for slot %d", block.Validator, block.Slot)
+	}
+	e := s.newExecution(block.Index)
+	e.releaseJailed()
+	for _, ev := range block.Evidence {
+		if err := e.applyEvidence(ev); err != nil {
+			return nil, fmt.Errorf("evidence %s: %v", ev.Hash(), err)
+		}
+	}
+	for _, tx := range block.Data {
+		if err := e.applyTransaction(tx); err != nil {
+			return nil, fmt.Errorf("transaction %s: %v", tx.Hash(), err)
+		}
+	}
+	e.endBlock(block.Validator)
+	if hash := e.nextValidatorSetHash(); block.NextValidatorSetHash != hash {
+		return nil, fmt.Errorf("next validator set hash %s does not match %s", block.NextValidatorSetHash, hash)
+	}
+	if root := e.stateRoot(); block.StateRoot != root {
+		return nil, fmt.Errorf("state root %s does not match %s", block.StateRoot, root)
+	}
+	return e, nil
+}
+
+// commitLocked writes an execution's accounts and state tree back to the state and returns the
+// journal needed to undo it. The execution's state root must have been computed.
+// The caller must hold the state mutex.
+func (s *State) commitLocked(e *execution) *stateJournal {
+	journal := &stateJournal{
+		accounts: make(map[string]*UserData, len(e.updates)),
+		evidence: e.evidence,
+		minted:   s.minted,
+		pocPool:  s.pocPool,
+	}
+	s.minted, s.pocPool = e.minted, e.pocPool
+	s.stateTree = e.tree
+	for address, user := range e.updates {
+		if prev, exists := s.Users[address]; exists {
+			journal.accounts[address] = &prev
+		} else {
+			journal.accounts[address] = nil
+		}
+		s.indexDelegationsLocked(address, journal.accounts[address], &user)
+		s.Users[address] = user
+		if user.PublicKey != "" {
+			s.Blockchain.Consensus.RegisterKey(address, user.PublicKey)
+		}
+		s.Blockchain.Consensus.SetJailed(address, user.JailedUntil > 0)
+	}
+	for _, offence := range e.evidence {
+		s.slashedEvidence[offence] = struct{}{}
+	}
+	if e.validators != nil {
+		journal.validators = s.Blockchain.Consensus.ValidatorSet()
+		s.Blockchain.Consensus.SetValidators(e.validators)
+	}
+	return journal
+}
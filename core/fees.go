@@ -0,0 +1,16 @@
+package core
+
+// collectFees pays the fees charged in a block to its validator, burning the configured share.
+func (e *execution) collectFees(address string) {
+	validator, exists := e.account(address)
+	if !exists || e.fees == 0 {
+		return
+	}
+	validator.Balance += e.fees - e.fees*e.state.Emission.FeeBurnPercent/100
+	e.updates[address] = validator
+}
+
+// higherFeeRate reports whether a pays more fee per byte than b, given their sizes.
+func higherFeeRate(a Transaction, aSize int, b Transaction, bSize int) bool {
+	return a.Fee*int64(bSize) > b.Fee*int64(aSize)
+}
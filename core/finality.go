@@ -0,0 +1,201 @@
+package core
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// VoteType distinguishes the two rounds of finality voting.
+type VoteType string
+
+const (
+	Prevote   VoteType = "prevote"
+	Precommit VoteType = "precommit"
+)
+
+// Vote is a validator's signed finality vote for a block in the triad tree.
+type Vote struct {
+	Type      VoteType
+	BlockHash string
+	Height    int
+	Validator string
+	Signature string
+}
+
+// payloadHash returns the digest covered by the vote signature.
+func (v *Vote) payloadHash() []byte {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%d:%s", v.Type, v.BlockHash, v.Height, v.Validator)))
+	return hash[:]
+}
+
+// Sign signs the vote with the validator's hex-encoded secp256k1 private key.
+func (v *Vote) Sign(privateKey string) error {
+	sig, err := signHash(privateKey, v.payloadHash())
+	if err != nil {
+		return fmt.Errorf("failed to sign vote: %v", err)
+	}
+	v.Signature = sig
+	return nil
+}
+
+// VerifySignature checks the vote signature against a public key.
+func (v *Vote) VerifySignature(pubKey string) bool {
+	return verifyHash(pubKey, v.payloadHash(), v.Signature)
+}
+
+// FinalityGadget collects validator votes and finalizes blocks that gather precommits from more
+// than two thirds of the bonded stake. Finalized blocks are never reorganized.
+type FinalityGadget struct {
+	consensus *Consensus
+	votes     map[string]map[VoteType]map[string]*Vote // Block hash -> type -> validator -> vote
+	heights   map[string]int                           // Block hash -> height of voted blocks
+	cast      map[string]*Vote                         // "validator:type:height" -> vote, to catch double votes
+	finalized *Block
+	mutex     sync.Mutex
+}
+
+// NewFinalityGadget creates a finality gadget with genesis as the initial finalized block.
+func NewFinalityGadget(consensus *Consensus, genesis *Block) *FinalityGadget {
+	return &FinalityGadget{
+		consensus: consensus,
+		votes:     make(map[string]map[VoteType]map[string]*Vote),
+		heights:   make(map[string]int),
+		cast:      make(map[string]*Vote),
+		finalized: genesis,
+	}
+}
+
+// AddVote verifies and records a vote and reports whether its block now has a quorum of that vote type.
+// A vote conflicting with one the validator already cast at the same height is rejected with an
+// *EquivocationError carrying the slashing evidence.
+func (fg *FinalityGadget) AddVote(vote *Vote) (bool, error) {
+	if vote.Type != Prevote && vote.Type != Precommit {
+		return false, fmt.Errorf("unknown vote type %q", vote.Type)
+	}
+	if vote.Height <= fg.FinalizedHead().Index {
+		return false, fmt.Errorf("vote at height %d is at or below the finalized head", vote.Height)
+	}
+	if fg.consensus.Stake(vote.Validator) <= 0 {
+		return false, fmt.Errorf("%s is not a bonded validator", vote.Validator)
+	}
+	pubKey, exists := fg.consensus.PublicKey(vote.Validator)
+	if !exists || !vote.VerifySignature(pubKey) {
+		return false, fmt.Errorf("invalid vote signature from %s", vote.Validator)
+	}
+
+	fg.mutex.Lock()
+	defer fg.mutex.Unlock()
+	castKey := fmt.Sprintf("%s:%s:%d", vote.Validator, vote.Type, vote.Height)
+	if prev, exists := fg.cast[castKey]; exists && prev.BlockHash != vote.BlockHash {
+		return false, &EquivocationError{Evidence: &Evidence{
+			Type:     EvidenceDoubleVote,
+			Offender: vote.Validator,
+			Votes:    [2]*Vote{prev, vote},
+		}}
+	}
+	fg.cast[castKey] = vote
+	byType, exists := fg.votes[vote.BlockHash]
+	if !exists {
+		byType = make(map[VoteType]map[string]*Vote)
+		fg.votes[vote.BlockHash] = byType
+		fg.heights[vote.BlockHash] = vote.Height
+	}
+	if byType[vote.Type] == nil {
+		byType[vote.Type] = make(map[string]*Vote)
+	}
+	byType[vote.Type][vote.Validator] = vote
+	return fg.hasQuorumLocked(vote.BlockHash, vote.Type), nil
+}
+
+// HasQuorum reports whether validators holding more than two thirds of the stake cast voteType for a block.
+func (fg *FinalityGadget) HasQuorum(blockHash string, voteType VoteType) bool {
+	fg.mutex.Lock()
+	defer fg.mutex.Unlock()
+	return fg.hasQuorumLocked(blockHash, voteType)
+}
+
+// hasQuorumLocked implements HasQuorum; the caller must hold the mutex.
+func (fg *FinalityGadget) hasQuorumLocked(blockHash string, voteType VoteType) bool {
+	total := fg.consensus.TotalStake()
+	if total <= 0 {
+		return false
+	}
+	voted := int64(0)
+	for validator := range fg.votes[blockHash][voteType] {
+		voted += fg.consensus.Stake(validator)
+	}
+	return 3*voted > 2*total
+}
+
+// FinalizedHead returns the highest finalized block.
+func (fg *FinalityGadget) FinalizedHead() *Block {
+	fg.mutex.Lock()
+	defer fg.mutex.Unlock()
+	return fg.finalized
+}
+
+// finalize marks a block as finalized and discards votes at or below its height.
+func (fg *FinalityGadget) finalize(block *Block) {
+	fg.mutex.Lock()
+	defer fg.mutex.Unlock()
+	fg.finalized = block
+	for hash, height := range fg.heights {
+		if height <= block.Index {
+			delete(fg.votes, hash)
+			delete(fg.heights, hash)
+		}
+	}
+	for key, vote := range fg.cast {
+		if vote.Height <= block.Index {
+			delete(fg.cast, key)
+		}
+	}
+}
+
+// AddVote records a finality vote. When a block gathers a precommit quorum it becomes the new
+// finalized head, and the fork choice and state move onto its branch if necessary.
+func (s *State) AddVote(vote *Vote) error {
+	s.Blockchain.Mutex.Lock()
+	defer s.Blockchain.Mutex.Unlock()
+
+	node, exists := s.Blockchain.Nodes[vote.BlockHash]
+	if !exists {
+		return fmt.Errorf("vote for unknown block %s", vote.BlockHash)
+	}
+	if node.Block.Index != vote.Height {
+		return fmt.Errorf("vote height %d does not match block height %d", vote.Height, node.Block.Index)
+	}
+	quorum, err := s.Blockchain.Finality.AddVote(vote)
+	if err != nil {
+		var equivocation *EquivocationError
+		if errors.As(err, &equivocation) {
+			s.Evidence.Add(equivocation.Evidence)
+		}
+		return err
+	}
+	finalized := s.Blockchain.Finality.FinalizedHead()
+	if vote.Type != Precommit || !quorum || node.Block.Index <= finalized.Index {
+		return nil
+	}
+	if !s.Blockchain.isAncestor(finalized.Hash, node) {
+		return fmt.Errorf("block %s conflicts with finalized block %s", node.Block.Hash, finalized.Hash)
+	}
+
+	if err := s.Store.StoreFinalized(node.Block.Hash); err != nil {
+		return err
+	}
+	s.Blockchain.Finality.finalize(node.Block)
+	slog.Info("Block finalized", "index", node.Block.Index, "hash", node.Block.Hash)
+	oldHead := s.Blockchain.ForkChoice.Head()
+	_, changed := s.Blockchain.ForkChoice.Update(s.Blockchain)
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if changed {
+		s.followHeadLocked(oldHead)
+	}
+	s.pruneLocked()
+	return nil
+}
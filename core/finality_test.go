@@ -0,0 +1,51 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// finalize casts the validator's prevote and precommit for block.
+func finalize(t *testing.T, s *State, validator testKey, block *Block) {
+	t.Helper()
+	for _, voteType := range []VoteType{Prevote, Precommit} {
+		vote := &Vote{Type: voteType, BlockHash: block.Hash, Height: block.Index, Validator: validator.addr}
+		if err := vote.Sign(validator.priv); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddVote(vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRestartKeepsFinalizedHead(t *testing.T) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	first := produce(t, s, validator, 1)
+	second := produce(t, s, validator, 2)
+	produce(t, s, validator, 3)
+	finalize(t, s, validator, second)
+	if got := s.Blockchain.Finality.FinalizedHead().Hash; got != second.Hash {
+		t.Fatalf("finalized head %s, want %s", got, second.Hash)
+	}
+
+	restarted := openTestState(t, copyStore(t, s.Store))
+	if got := restarted.Blockchain.Finality.FinalizedHead().Hash; got != second.Hash {
+		t.Fatalf("restored finalized head %s, want %s", got, second.Hash)
+	}
+	assertSameState(t, restarted, s)
+
+	// A fork below the finalized head is still rejected after the restart
+	setSlot(restarted, 4)
+	block := NewBlock(first.Index+1, 4, nil, first.Hash, validator.addr)
+	block.Timestamp = restarted.now().UnixNano()
+	block.ValidatorSetHash = restarted.Blockchain.Consensus.ValidatorSet().Hash()
+	block.seal()
+	if err := block.SignBlock(validator.priv); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.AddBlock(block); err == nil || !strings.Contains(err.Error(), "finalized") {
+		t.Fatalf("error %v, want a fork below the finalized head rejected", err)
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import "sync"
+
+// ForkChoiceRule picks the canonical head of a triad tree. Implementations are called with the
+// blockchain mutex held.
+type ForkChoiceRule interface {
+	SelectHead(bc *TriadBlockchain) *TriadNode
+}
+
+// HeaviestSubtree walks from the finalized head, or the pruned height if that is higher, at each
+// level following the child whose subtree carries the most validator weight (stake × reputation of
+// each block's proposer). Ties, including trees without any weighted validators, fall back to the
+// longest branch and then to the lowest hash.
+type HeaviestSubtree struct{}
+
+// LongestBranch picks the deepest descendant of the finalized head, or the pruned height if that is
+// higher, preferring the lowest hash among equal heights.
+type LongestBranch struct{}
+
+// SelectHead implements ForkChoiceRule.
+func (HeaviestSubtree) SelectHead(bc *TriadBlockchain) *TriadNode {
+	// Only the subtree above the fork root can hold the head. Its weight and depth are accumulated
+	// bottom-up by visiting its blocks in reverse depth-first order, descendants before ancestors.
+	root := bc.forkRoot()
+	var nodes []*TriadNode
+	it := bc.DFS(root)
+	for node := it.Next(); node != nil; node = it.Next() {
+		nodes = append(nodes, node)
+	}
+
+	weights := make(map[*TriadNode]int64, len(nodes))
+	depths := make(map[*TriadNode]int, len(nodes))
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		weights[node] += bc.Consensus.Weight(node.Block.Validator)
+		if depths[node] < node.Block.Index {
+			depths[node] = node.Block.Index
+		}
+		if node != root {
+			parent := bc.Nodes[node.Block.ParentHash]
+			weights[parent] += weights[node]
+			if depths[parent] < depths[node] {
+				depths[parent] = depths[node]
+			}
+		}
+	}
+
+	head := root
+	for {
+		var best *TriadNode
+		for _, child := range head.Children {
+			if child == nil {
+				continue
+			}
+			if best == nil || weights[child] > weights[best] ||
+				(weights[child] == weights[best] && (depths[child] > depths[best] ||
+					(depths[child] == depths[best] && child.Block.Hash < best.Block.Hash))) {
+				best = child
+			}
+		}
+		if best == nil {
+			return head
+		}
+		head = best
+	}
+}
+
+// SelectHead implements ForkChoiceRule.
+func (LongestBranch) SelectHead(bc *TriadBlockchain) *TriadNode {
+	head := bc.forkRoot()
+	it := bc.DFS(head)
+	for node := it.Next(); node != nil; node = it.Next() {
+		block := node.Block
+		if block.Index > head.Block.Index || (block.Index == head.Block.Index && block.Hash < head.Block.Hash) {
+			head = node
+		}
+	}
+	return head
+}
+
+// HeadEvent is published when the canonical head changes.
+type HeadEvent struct {
+	OldHead *Block
+	NewHead *Block
+}
+
+// ForkChoice tracks the canonical head of a triad tree under a pluggable rule and notifies
+// subscribers when it changes.
+type ForkChoice struct {
+	rule        ForkChoiceRule
+	head        *TriadNode
+	path        []*Block // Canonical path from genesis to head
+	subscribers []chan HeadEvent
+	mutex       sync.Mutex
+}
+
+// NewForkChoice creates a fork choice using rule.
+func NewForkChoice(rule ForkChoiceRule) *ForkChoice {
+	return &ForkChoice{rule: rule}
+}
+
+// Update re-evaluates the head of bc and reports whether it changed. The caller must hold the
+// blockchain mutex.
+func (fc *ForkChoice) Update(bc *TriadBlockchain) (HeadEvent, bool) {
+	head := fc.rule.SelectHead(bc)
+
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	if head == fc.head {
+		return HeadEvent{}, false
+	}
+
+	path := bc.lineage(head).Blocks(0)
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	event := HeadEvent{NewHead: head.Block}
+	if fc.head != nil {
+		event.OldHead = fc.head.Block
+	}
+	fc.head = head
+	fc.path = path
+	for _, ch := range fc.subscribers {
+		// Never block block import on a slow subscriber
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return event, true
+}
+
+// Head returns the canonical head block.
+func (fc *ForkChoice) Head() *Block {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	if fc.head == nil {
+		return nil
+	}
+	return fc.head.Block
+}
+
+// CanonicalPath returns the blocks from genesis to the canonical head.
+func (fc *ForkChoice) CanonicalPath() []*Block {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	return append([]*Block(nil), fc.path...)
+}
+
+// IsCanonical reports whether a block is on the canonical path.
+func (fc *ForkChoice) IsCanonical(block *Block) bool {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	return block.Index < len(fc.path) && fc.path[block.Index].Hash == block.Hash
+}
+
+// Subscribe returns a channel receiving every subsequent head change. Events are dropped for
+// subscribers that fall behind.
+func (fc *ForkChoice) Subscribe() <-chan HeadEvent {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	ch := make(chan HeadEvent, 16)
+	fc.subscribers = append(fc.subscribers, ch)
+	return ch
+}
+
+// Unsubscribe stops delivery to a channel returned by Subscribe and closes it.
+func (fc *ForkChoice) Unsubscribe(sub <-chan HeadEvent) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	for i, ch := range fc.subscribers {
+		if ch == sub {
+			fc.subscribers = append(fc.subscribers[:i], fc.subscribers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
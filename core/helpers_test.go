@@ -0,0 +1,119 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testKey is a key pair and the address derived from it.
+type testKey struct {
+	priv, pub, addr string
+}
+
+func newTestKey(t *testing.T) testKey {
+	t.Helper()
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := AddressFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{priv: priv, pub: pub, addr: addr}
+}
+
+// openTestState opens a state over store with one-second slots and a clock stopped at genesis.
+func openTestState(t *testing.T, store *Store) *State {
+	t.Helper()
+	config := DefaultStateConfig()
+	config.SlotDuration = time.Second
+	return openTestStateWith(t, store, config)
+}
+
+// openTestStateWith opens a state over store with config and a clock stopped at genesis.
+func openTestStateWith(t *testing.T, store *Store, config StateConfig) *State {
+	t.Helper()
+	s, err := NewState(store, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setSlot(s, 0)
+	return s
+}
+
+// newTestState creates a state on a fresh in-memory store with validator as its genesis validator.
+func newTestState(t *testing.T, validator testKey) *State {
+	t.Helper()
+	s := openTestState(t, NewMemoryBackedStore())
+	if err := s.AddGenesisValidator(validator.addr, "device", validator.pub, minStake); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// setSlot moves the state's clock just past the start of slot.
+func setSlot(s *State, slot uint64) {
+	now := time.Unix(0, s.Blockchain.Root.Block.Timestamp).Add(time.Duration(slot)*s.SlotDuration + time.Millisecond)
+	s.now = func() time.Time { return now }
+}
+
+// produce moves the clock to slot and produces a block with txs on the canonical head.
+func produce(t *testing.T, s *State, validator testKey, slot uint64, txs ...Transaction) *Block {
+	t.Helper()
+	setSlot(s, slot)
+	block, err := s.ProduceBlock(txs, nil, slot, s.Blockchain.ForkChoice.Head().Hash, validator.priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// signedTx fills in the sender of tx and signs it with the sender's key.
+func signedTx(t *testing.T, from testKey, tx Transaction) Transaction {
+	t.Helper()
+	tx.From = from.addr
+	if err := tx.Sign(from.priv); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// registerTx returns a signed register transaction creating the account of key.
+func registerTx(t *testing.T, key testKey) Transaction {
+	t.Helper()
+	payload, err := json.Marshal(RegisterPayload{DeviceID: "device"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signedTx(t, key, Transaction{Type: TxRegister, Nonce: 1, Payload: payload})
+}
+
+// copyStore returns an in-memory copy of everything in store.
+func copyStore(t *testing.T, store *Store) *Store {
+	t.Helper()
+	dst := NewMemoryStore()
+	err := store.kv.IteratePrefix(nil, nil, func(key, value []byte) bool {
+		return dst.Put(key, value) == nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(dst)
+}
+
+// assertSameState fails unless both states hold the same accounts, supply and head.
+func assertSameState(t *testing.T, got, want *State) {
+	t.Helper()
+	if !reflect.DeepEqual(got.Users, want.Users) {
+		t.Errorf("accounts differ:\n got %+v\nwant %+v", got.Users, want.Users)
+	}
+	if got.minted != want.minted || got.pocPool != want.pocPool {
+		t.Errorf("supply differs: minted %d, pool %d; want %d, %d", got.minted, got.pocPool, want.minted, want.pocPool)
+	}
+	if got, want := got.Blockchain.ForkChoice.Head().Hash, want.Blockchain.ForkChoice.Head().Hash; got != want {
+		t.Errorf("head %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// ErrNotFound is returned by KVStore.Get when a key does not exist.
+var ErrNotFound = errors.New("key not found")
+
+// KVStore is the key-value backend the node's storage is written against.
+type KVStore interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	// IteratePrefix calls fn for each key with the given prefix in ascending key order until fn returns false.
+	// If start is non-nil, iteration begins at the first key >= start.
+	IteratePrefix(prefix, start []byte, fn func(key, value []byte) bool) error
+	NewBatch() Batch
+	Close() error
+}
+
+// Batch collects writes that are applied atomically by Write.
+type Batch interface {
+	Put(key, value []byte)
+	Delete(key []byte)
+	Write() error
+}
+
+// LevelDBStore is a KVStore backed by a LevelDB database on disk.
+type LevelDBStore struct {
+	db *leveldb.DB
+}
+
+// NewLevelDBStore opens (or creates) the LevelDB database at path.
+func NewLevelDBStore(path string) (*LevelDBStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	return &LevelDBStore{db: db}, nil
+}
+
+func (l *LevelDBStore) Get(key []byte) ([]byte, error) {
+	value, err := l.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (l *LevelDBStore) Put(key, value []byte) error {
+	return l.db.Put(key, value, nil)
+}
+
+func (l *LevelDBStore) Delete(key []byte) error {
+	return l.db.Delete(key, nil)
+}
+
+func (l *LevelDBStore) IteratePrefix(prefix, start []byte, fn func(key, value []byte) bool) error {
+	keyRange := util.BytesPrefix(prefix)
+	if start != nil && bytes.Compare(start, keyRange.Start) > 0 {
+		keyRange.Start = start
+	}
+	iter := l.db.NewIterator(keyRange, nil)
+	defer iter.Release()
+	for iter.Next() {
+		// The iterator reuses its buffers, so hand out copies
+		key := append([]byte(nil), iter.Key()...)
+		value := append([]byte(nil), iter.Value()...)
+		if !fn(key, value) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+func (l *LevelDBStore) NewBatch() Batch {
+	return &levelDBBatch{db: l.db, batch: new(leveldb.Batch)}
+}
+
+func (l *LevelDBStore) Close() error {
+	return l.db.Close()
+}
+
+type levelDBBatch struct {
+	db    *leveldb.DB
+	batch *leveldb.Batch
+}
+
+func (b *levelDBBatch) Put(key, value []byte) {
+	b.batch.Put(key, value)
+}
+
+func (b *levelDBBatch) Delete(key []byte) {
+	b.batch.Delete(key)
+}
+
+func (b *levelDBBatch) Write() error {
+	return b.db.Write(b.batch, nil)
+}
+
+// MemoryStore is a KVStore held entirely in memory, for tests and simulations.
+type MemoryStore struct {
+	data  map[string][]byte
+	mutex sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+func (m *MemoryStore) Get(key []byte) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	value, exists := m.data[string(key)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (m *MemoryStore) Put(key, value []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+func (m *MemoryStore) Delete(key []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.data, string(key))
+	return nil
+}
+
+func (m *MemoryStore) IteratePrefix(prefix, start []byte, fn func(key, value []byte) bool) error {
+	// Snapshot matching entries so fn may write to the store
+	m.mutex.RLock()
+	var keys []string
+	for key := range m.data {
+		if bytes.HasPrefix([]byte(key), prefix) && bytes.Compare([]byte(key), start) >= 0 {
+			keys = append(keys, key)
+		}
+	}
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		values[key] = append([]byte(nil), m.data[key]...)
+	}
+	m.mutex.RUnlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !fn([]byte(key), values[key]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) NewBatch() Batch {
+	return &memoryBatch{store: m}
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+type memoryOp struct {
+	key    string
+	value  []byte
+	delete bool
+}
+
+type memoryBatch struct {
+	store *MemoryStore
+	ops   []memoryOp
+}
+
+func (b *memoryBatch) Put(key, value []byte) {
+	b.ops = append(b.ops, memoryOp{key: string(key), value: append([]byte(nil), value...)})
+}
+
+func (b *memoryBatch) Delete(key []byte) {
+	b.ops = append(b.ops, memoryOp{key: string(key), delete: true})
+}
+
+func (b *memoryBatch) Write() error {
+	b.store.mutex.Lock()
+	defer b.store.mutex.Unlock()
+	for _, op := range b.ops {
+		if op.delete {
+			delete(b.store.data, op.key)
+		} else {
+			b.store.data[op.key] = op.value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// kvBackends returns a fresh store of every KVStore implementation.
+func kvBackends(t *testing.T) map[string]KVStore {
+	t.Helper()
+	leveldb, err := NewLevelDBStore(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { leveldb.Close() })
+	return map[string]KVStore{"memory": NewMemoryStore(), "leveldb": leveldb}
+}
+
+// kvEntries collects the entries IteratePrefix visits, stopping after limit if it is positive.
+func kvEntries(t *testing.T, kv KVStore, prefix, start string, limit int) []string {
+	t.Helper()
+	var startKey []byte
+	if start != "" {
+		startKey = []byte(start)
+	}
+	var entries []string
+	err := kv.IteratePrefix([]byte(prefix), startKey, func(key, value []byte) bool {
+		entries = append(entries, string(key)+"="+string(value))
+		return limit <= 0 || len(entries) < limit
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestKVStoreParity(t *testing.T) {
+	seed := map[string]string{"a:1": "one", "a:2": "two", "a:3": "three", "ab": "x", "b:1": "other"}
+	tests := []struct {
+		name   string
+		prefix string
+		start  string
+		limit  int
+		want   []string
+	}{
+		{name: "prefix", prefix: "a:", want: []string{"a:1=one", "a:2=two", "a:3=three"}},
+		{name: "shorter prefix", prefix: "a", want: []string{"a:1=one", "a:2=two", "a:3=three", "ab=x"}},
+		{name: "start inside prefix", prefix: "a:", start: "a:2", want: []string{"a:2=two", "a:3=three"}},
+		{name: "start before prefix", prefix: "a:", start: "0", want: []string{"a:1=one", "a:2=two", "a:3=three"}},
+		{name: "start past prefix", prefix: "a:", start: "a:9"},
+		{name: "early stop", prefix: "a:", limit: 2, want: []string{"a:1=one", "a:2=two"}},
+		{name: "no match", prefix: "c"},
+		{name: "everything", want: []string{"a:1=one", "a:2=two", "a:3=three", "ab=x", "b:1=other"}},
+	}
+	for name, kv := range kvBackends(t) {
+		for key, value := range seed {
+			if err := kv.Put([]byte(key), []byte(value)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				if got := kvEntries(t, kv, tt.prefix, tt.start, tt.limit); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestKVStoreGetPutDelete(t *testing.T) {
+	for name, kv := range kvBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := kv.Get([]byte("missing")); err != ErrNotFound {
+				t.Fatalf("missing key error %v, want ErrNotFound", err)
+			}
+			value := []byte("value")
+			if err := kv.Put([]byte("key"), value); err != nil {
+				t.Fatal(err)
+			}
+			// The store must not alias the caller's buffers
+			value[0] = 'X'
+			got, err := kv.Get([]byte("key"))
+			if err != nil || string(got) != "value" {
+				t.Fatalf("got %q, %v; want \"value\"", got, err)
+			}
+			got[0] = 'Y'
+			if again, _ := kv.Get([]byte("key")); string(again) != "value" {
+				t.Fatalf("modifying a read changed the stored value to %q", again)
+			}
+			if err := kv.Put([]byte("key"), []byte("new")); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := kv.Get([]byte("key")); string(got) != "new" {
+				t.Fatalf("overwritten value %q, want \"new\"", got)
+			}
+			if err := kv.Delete([]byte("key")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := kv.Get([]byte("key")); err != ErrNotFound {
+				t.Fatalf("deleted key error %v, want ErrNotFound", err)
+			}
+			if err := kv.Delete([]byte("key")); err != nil {
+				t.Fatalf("deleting a missing key failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestKVStoreBatch(t *testing.T) {
+	for name, kv := range kvBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := kv.Put([]byte("old"), []byte("1")); err != nil {
+				t.Fatal(err)
+			}
+			batch := kv.NewBatch()
+			batch.Put([]byte("a"), []byte("1"))
+			batch.Put([]byte("b"), []byte("2"))
+			batch.Delete([]byte("old"))
+			batch.Put([]byte("a"), []byte("3")) // Later operations win
+			// Nothing is visible before Write
+			if _, err := kv.Get([]byte("a")); err != ErrNotFound {
+				t.Fatalf("batched put visible before write: %v", err)
+			}
+			if err := batch.Write(); err != nil {
+				t.Fatal(err)
+			}
+			if got := kvEntries(t, kv, "", "", 0); !reflect.DeepEqual(got, []string{"a=3", "b=2"}) {
+				t.Fatalf("got %v after batch write", got)
+			}
+		})
+	}
+}
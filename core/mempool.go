@@ -0,0 +1,231 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Mempool holds verified transactions waiting for block inclusion, kept sorted by sender and nonce.
+type Mempool struct {
+	state         *State
+	bySender      map[string][]Transaction // Sender -> pending transactions sorted by nonce
+	hashes        map[string]struct{}      // Hashes of all pending transactions
+	maxSize       int
+	minFeePerByte int64
+	mutex         sync.Mutex
+}
+
+// NewMempool creates a mempool that verifies transactions against state, holds at most maxSize of
+// them and rejects any paying less than minFeePerByte.
+func NewMempool(state *State, maxSize int, minFeePerByte int64) *Mempool {
+	return &Mempool{
+		state:         state,
+		bySender:      make(map[string][]Transaction),
+		hashes:        make(map[string]struct{}),
+		maxSize:       maxSize,
+		minFeePerByte: minFeePerByte,
+	}
+}
+
+// Add verifies a transaction and queues it, evicting the lowest fee rate when full. Registrations
+// are exempt from the minimum fee: the account they create starts empty and could not pay it.
+func (m *Mempool) Add(tx Transaction) error {
+	if size := tx.Size(); !txKinds[tx.Type].newAccount && tx.Fee < m.minFeePerByte*int64(size) {
+		return fmt.Errorf("fee %d below minimum %d for %d bytes", tx.Fee, m.minFeePerByte*int64(size), size)
+	}
+	if err := m.state.VerifyTransaction(tx); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	hash := tx.Hash()
+	if _, exists := m.hashes[hash]; exists {
+		return fmt.Errorf("transaction %s already pending", hash)
+	}
+	queue := m.bySender[tx.From]
+	i := sort.Search(len(queue), func(i int) bool { return queue[i].Nonce >= tx.Nonce })
+	if i < len(queue) && queue[i].Nonce == tx.Nonce {
+		return fmt.Errorf("nonce %d already pending for %s", tx.Nonce, tx.From)
+	}
+
+	if len(m.hashes) >= m.maxSize {
+		if err := m.evict(tx); err != nil {
+			return err
+		}
+		queue = m.bySender[tx.From]
+		i = sort.Search(len(queue), func(i int) bool { return queue[i].Nonce >= tx.Nonce })
+	}
+
+	queue = append(queue, Transaction{})
+	copy(queue[i+1:], queue[i:])
+	queue[i] = tx
+	m.bySender[tx.From] = queue
+	m.hashes[hash] = struct{}{}
+	return nil
+}
+
+// evict drops the lowest fee-rate transaction among the highest-nonce transaction of each sender,
+// so sender queues stay free of gaps. The incoming transaction is rejected instead if it pays no
+// more than the transaction it would displace, or would itself be the one evicted.
+func (m *Mempool) evict(incoming Transaction) error {
+	var victim *Transaction
+	for _, sender := range m.senders() {
+		queue := m.bySender[sender]
+		last := &queue[len(queue)-1]
+		if victim == nil || higherFeeRate(*victim, victim.Size(), *last, last.Size()) {
+			victim = last
+		}
+	}
+	if victim == nil || !higherFeeRate(incoming, incoming.Size(), *victim, victim.Size()) {
+		return fmt.Errorf("mempool full")
+	}
+	if victim.From == incoming.From && incoming.Nonce > victim.Nonce {
+		return fmt.Errorf("mempool full")
+	}
+	m.removeLocked(*victim)
+	return nil
+}
+
+// Pending returns up to max transactions that can be applied in order to the current state,
+// highest fee per byte first. Each sender's transactions stay in ascending nonce order and are
+// only taken while affordable from the sender's balance, and no more registrations are taken than
+// a block may hold. Stale transactions whose nonce has already been used are dropped.
+func (m *Mempool) Pending(max int) []Transaction {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	type cursor struct {
+		queue   []Transaction
+		sizes   []int
+		next    int
+		balance int64
+	}
+	var cursors []*cursor
+	for _, sender := range m.senders() {
+		user, exists := m.state.GetData(sender)
+		if !exists {
+			// Only a queued registration can bring an unknown sender's account into existence
+			if !m.registering(sender) {
+				continue
+			}
+			user = UserData{}
+		}
+		c := &cursor{balance: user.Balance}
+		for _, tx := range append([]Transaction(nil), m.bySender[sender]...) {
+			if tx.Nonce <= user.LastNonce {
+				m.removeLocked(tx)
+				continue
+			}
+			c.queue = append(c.queue, tx)
+			c.sizes = append(c.sizes, tx.Size())
+		}
+		cursors = append(cursors, c)
+	}
+
+	var batch []Transaction
+	registrations := 0
+	for len(batch) < max {
+		var best *cursor
+		for _, c := range cursors {
+			if c.next >= len(c.queue) || c.queue[c.next].overdraws(c.balance) {
+				continue
+			}
+			if c.queue[c.next].Type == TxRegister && registrations >= maxBlockRegistrations {
+				continue
+			}
+			if best == nil || higherFeeRate(c.queue[c.next], c.sizes[c.next], best.queue[best.next], best.sizes[best.next]) {
+				best = c
+			}
+		}
+		if best == nil {
+			break
+		}
+		tx := best.queue[best.next]
+		if tx.Type == TxRegister {
+			registrations++
+		}
+		best.balance -= tx.debit()
+		best.next++
+		batch = append(batch, tx)
+	}
+	return batch
+}
+
+// Remove drops transactions that were included in a block.
+func (m *Mempool) Remove(txs []Transaction) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, tx := range txs {
+		m.removeLocked(tx)
+	}
+}
+
+// Prune drops every pending transaction whose nonce is no longer above the sender's last nonce,
+// and those of unknown senders without a queued registration.
+func (m *Mempool) Prune() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, sender := range m.senders() {
+		user, exists := m.state.GetData(sender)
+		if !exists && m.registering(sender) {
+			continue
+		}
+		queue := append([]Transaction(nil), m.bySender[sender]...)
+		for _, tx := range queue {
+			if !exists || tx.Nonce <= user.LastNonce {
+				m.removeLocked(tx)
+			}
+		}
+	}
+}
+
+// Len returns the number of pending transactions.
+func (m *Mempool) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.hashes)
+}
+
+// removeLocked removes a transaction; the caller must hold the mutex.
+func (m *Mempool) removeLocked(tx Transaction) {
+	hash := tx.Hash()
+	if _, exists := m.hashes[hash]; !exists {
+		return
+	}
+	delete(m.hashes, hash)
+	queue := m.bySender[tx.From]
+	for i := range queue {
+		if queue[i].Nonce == tx.Nonce {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(m.bySender, tx.From)
+	} else {
+		m.bySender[tx.From] = queue
+	}
+}
+
+// registering reports whether a register transaction from sender is queued; the caller must hold the mutex.
+func (m *Mempool) registering(sender string) bool {
+	for _, tx := range m.bySender[sender] {
+		if tx.Type == TxRegister {
+			return true
+		}
+	}
+	return false
+}
+
+// senders returns the pending senders in address order.
+func (m *Mempool) senders() []string {
+	senders := make([]string, 0, len(m.bySender))
+	for sender := range m.bySender {
+		senders = append(senders, sender)
+	}
+	sort.Strings(senders)
+	return senders
+}
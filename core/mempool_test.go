@@ -0,0 +1,203 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// mempoolTx describes a transfer from one of the test senders.
+type mempoolTx struct {
+	sender int
+	nonce  uint64
+	amount int64
+	fee    int64
+}
+
+// mempoolTestState returns a state holding funded accounts for the given balances.
+func mempoolTestState(t *testing.T, balances ...int64) (*State, []testKey) {
+	t.Helper()
+	s := openTestState(t, NewMemoryBackedStore())
+	keys := make([]testKey, len(balances))
+	for i, balance := range balances {
+		keys[i] = newTestKey(t)
+		s.Users[keys[i].addr] = UserData{PublicKey: keys[i].pub, Balance: balance, Reputation: NewReputation()}
+	}
+	return s, keys
+}
+
+func (m mempoolTx) sign(t *testing.T, keys []testKey) Transaction {
+	t.Helper()
+	to := keys[(m.sender+1)%len(keys)].addr
+	return signedTx(t, keys[m.sender], Transaction{To: to, Amount: m.amount, Fee: m.fee, Nonce: m.nonce})
+}
+
+func TestMempoolPending(t *testing.T) {
+	tests := []struct {
+		name     string
+		balances []int64
+		txs      []mempoolTx
+		max      int
+		want     []int // Indexes into txs, in the expected order
+	}{
+		{
+			name:     "highest fee rate first",
+			balances: []int64{1000, 1000, 1000},
+			txs:      []mempoolTx{{0, 1, 1, 30}, {1, 1, 1, 10}, {2, 1, 1, 20}},
+			max:      10,
+			want:     []int{0, 2, 1},
+		},
+		{
+			name:     "nonce order within a sender",
+			balances: []int64{1000, 1000},
+			txs:      []mempoolTx{{0, 2, 1, 50}, {0, 1, 1, 10}, {1, 1, 1, 20}},
+			max:      10,
+			want:     []int{2, 1, 0},
+		},
+		{
+			name:     "stops at the sender balance",
+			balances: []int64{100, 1000},
+			txs:      []mempoolTx{{0, 1, 90, 5}, {0, 2, 10, 50}, {1, 1, 1, 1}},
+			max:      10,
+			want:     []int{0, 2},
+		},
+		{
+			name:     "limited to max",
+			balances: []int64{1000, 1000, 1000},
+			txs:      []mempoolTx{{0, 1, 1, 30}, {1, 1, 1, 10}, {2, 1, 1, 20}},
+			max:      2,
+			want:     []int{0, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, keys := mempoolTestState(t, tt.balances...)
+			m := NewMempool(s, 100, 0)
+			txs := make([]Transaction, len(tt.txs))
+			for i, desc := range tt.txs {
+				txs[i] = desc.sign(t, keys)
+				if err := m.Add(txs[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			pending := m.Pending(tt.max)
+			if len(pending) != len(tt.want) {
+				t.Fatalf("got %d pending transactions, want %d", len(pending), len(tt.want))
+			}
+			for i, index := range tt.want {
+				if pending[i].Hash() != txs[index].Hash() {
+					t.Fatalf("pending[%d] is not transaction %d", i, index)
+				}
+			}
+		})
+	}
+}
+
+func TestMempoolEviction(t *testing.T) {
+	// The pool is full with one transaction from each of the first two senders
+	existing := []mempoolTx{{0, 1, 1, 10}, {1, 1, 1, 20}}
+	tests := []struct {
+		name     string
+		incoming mempoolTx
+		wantErr  string
+		evicted  int // Index into existing of the evicted transaction; -1 if none
+	}{
+		{name: "higher fee rate evicts the lowest", incoming: mempoolTx{2, 1, 1, 30}, evicted: 0},
+		{name: "lower fee rate rejected", incoming: mempoolTx{2, 1, 1, 5}, wantErr: "mempool full", evicted: -1},
+		{name: "equal fee rate rejected", incoming: mempoolTx{2, 1, 1, 10}, wantErr: "mempool full", evicted: -1},
+		{name: "sender of the victim cannot leave a gap", incoming: mempoolTx{0, 2, 1, 50}, wantErr: "mempool full", evicted: -1},
+		{name: "other sender queue grows", incoming: mempoolTx{1, 2, 1, 30}, evicted: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, keys := mempoolTestState(t, 1000, 1000, 1000)
+			m := NewMempool(s, len(existing), 0)
+			txs := make([]Transaction, len(existing))
+			for i, desc := range existing {
+				txs[i] = desc.sign(t, keys)
+				if err := m.Add(txs[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := m.Add(tt.incoming.sign(t, keys))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if m.Len() != len(existing) {
+				t.Fatalf("pool holds %d transactions, want %d", m.Len(), len(existing))
+			}
+			for i, tx := range txs {
+				_, pending := m.hashes[tx.Hash()]
+				if pending == (i == tt.evicted) {
+					t.Fatalf("transaction %d pending: %v, evicted: %d", i, pending, tt.evicted)
+				}
+			}
+		})
+	}
+}
+
+func TestMempoolAddRejects(t *testing.T) {
+	s, keys := mempoolTestState(t, 10000, 10000)
+	m := NewMempool(s, 100, 1)
+	queued := signedTx(t, keys[0], Transaction{To: keys[1].addr, Amount: 1, Fee: 1000, Nonce: 1})
+	if err := m.Add(queued); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		tx      Transaction
+		wantErr string
+	}{
+		{name: "duplicate", tx: queued, wantErr: "already pending"},
+		{name: "duplicate nonce", tx: signedTx(t, keys[0], Transaction{To: keys[1].addr, Amount: 2, Fee: 1000, Nonce: 1}), wantErr: "nonce 1 already pending"},
+		{name: "fee below minimum", tx: signedTx(t, keys[1], Transaction{To: keys[0].addr, Amount: 1, Fee: 1, Nonce: 1}), wantErr: "below minimum"},
+		{name: "negative amount", tx: signedTx(t, keys[1], Transaction{To: keys[0].addr, Amount: -500, Nonce: 1, Fee: 1000}), wantErr: "amount must be positive"},
+		{name: "unknown sender", tx: signedTx(t, newTestKey(t), Transaction{To: keys[0].addr, Amount: 1, Fee: 1000, Nonce: 1}), wantErr: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Add(tt.tx)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMempoolAcceptsRegisterWithoutFee(t *testing.T) {
+	s, _ := mempoolTestState(t)
+	m := NewMempool(s, 100, 1)
+	key := newTestKey(t)
+	if err := m.Add(registerTx(t, key)); err != nil {
+		t.Fatalf("registration rejected under a minimum fee: %v", err)
+	}
+	if pending := m.Pending(10); len(pending) != 1 || pending[0].Type != TxRegister {
+		t.Fatalf("pending %v, want the registration", pending)
+	}
+}
+
+func TestRegistrationsCappedPerBlock(t *testing.T) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	m := NewMempool(s, 100, 0)
+	var txs []Transaction
+	for i := 0; i < maxBlockRegistrations+2; i++ {
+		tx := registerTx(t, newTestKey(t))
+		if err := m.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	if pending := m.Pending(100); len(pending) != maxBlockRegistrations {
+		t.Fatalf("%d registrations pending for one block, want %d", len(pending), maxBlockRegistrations)
+	}
+
+	// A block never applies more registrations than the cap, whatever it is handed
+	block := produce(t, s, validator, 1, txs...)
+	if len(block.Data) != maxBlockRegistrations {
+		t.Fatalf("block holds %d registrations, want %d", len(block.Data), maxBlockRegistrations)
+	}
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Leaves and inner nodes are hashed with distinct prefixes so an inner node can never be passed
+// off as a leaf.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleStep is one level of an inclusion proof: the sibling hash and which side it sits on.
+type MerkleStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"` // The sibling is the left operand
+}
+
+// MerkleProof proves that a transaction is included under a block header's transaction root.
+type MerkleProof struct {
+	TxHash string       `json:"txHash"`
+	Steps  []MerkleStep `json:"steps"` // From the leaf up to the root
+}
+
+// MerkleRoot returns the hex-encoded Merkle root over hex-encoded leaf hashes. Unpaired nodes are
+// promoted to the next level unchanged; the root of no leaves is the hash of the empty string.
+func MerkleRoot(hashes []string) string {
+	level, err := merkleLeaves(hashes)
+	if err != nil || len(level) == 0 {
+		empty := sha256.Sum256(nil)
+		return hex.EncodeToString(empty[:])
+	}
+	for len(level) > 1 {
+		level = merkleLevel(level)
+	}
+	return hex.EncodeToString(level[0])
+}
+
+// TxRoot returns the Merkle root of a block's transactions.
+func TxRoot(txs []Transaction) string {
+	hashes := make([]string, len(txs))
+	for i := range txs {
+		hashes[i] = txs[i].Hash()
+	}
+	return MerkleRoot(hashes)
+}
+
+// evidenceRoot returns the Merkle root of a block's slashing evidence.
+func evidenceRoot(evidence []*Evidence) string {
+	hashes := make([]string, len(evidence))
+	for i, ev := range evidence {
+		hashes[i] = ev.Hash()
+	}
+	return MerkleRoot(hashes)
+}
+
+// TxProof builds an inclusion proof for the transaction with txHash in the block.
+func (b *Block) TxProof(txHash string) (*MerkleProof, error) {
+	hashes := make([]string, len(b.Data))
+	index := -1
+	for i := range b.Data {
+		hashes[i] = b.Data[i].Hash()
+		if hashes[i] == txHash {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("transaction %s not in block %s", txHash, b.Hash)
+	}
+	level, err := merkleLeaves(hashes)
+	if err != nil {
+		return nil, err
+	}
+	proof := &MerkleProof{TxHash: txHash}
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof.Steps = append(proof.Steps, MerkleStep{
+				Hash: hex.EncodeToString(level[sibling]),
+				Left: sibling < index,
+			})
+		}
+		level = merkleLevel(level)
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyTxProof checks an inclusion proof against a block header alone.
+func VerifyTxProof(header BlockHeader, proof *MerkleProof) bool {
+	leaves, err := merkleLeaves([]string{proof.TxHash})
+	if err != nil {
+		return false
+	}
+	current := leaves[0]
+	for _, step := range proof.Steps {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return false
+		}
+		if step.Left {
+			current = merkleNode(sibling, current)
+		} else {
+			current = merkleNode(current, sibling)
+		}
+	}
+	return hex.EncodeToString(current) == header.TxRoot
+}
+
+// merkleLeaves decodes hex hashes and hashes them as leaves.
+func merkleLeaves(hashes []string) ([][]byte, error) {
+	leaves := make([][]byte, len(hashes))
+	for i, h := range hashes {
+		raw, err := hex.DecodeString(h)
+		if err != nil {
+			return nil, fmt.Errorf("invalid leaf hash %q: %v", h, err)
+		}
+		leaf := sha256.Sum256(append([]byte{merkleLeafPrefix}, raw...))
+		leaves[i] = leaf[:]
+	}
+	return leaves, nil
+}
+
+// merkleLevel hashes a level's nodes in pairs, promoting an unpaired last node.
+func merkleLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+		} else {
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+	}
+	return next
+}
+
+// merkleNode hashes two child nodes into their parent.
+func merkleNode(left, right []byte) []byte {
+	data := append([]byte{merkleNodePrefix}, left...)
+	node := sha256.Sum256(append(data, right...))
+	return node[:]
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+// merkleTestBlock returns a block holding n distinct transactions.
+func merkleTestBlock(n int) *Block {
+	txs := make([]Transaction, n)
+	for i := range txs {
+		txs[i] = Transaction{From: "sender", To: "recipient", Amount: int64(i + 1), Nonce: uint64(i + 1)}
+	}
+	return NewBlock(1, 1, txs, "parent", "v")
+}
+
+func TestTxProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		t.Run(fmt.Sprintf("%d transactions", n), func(t *testing.T) {
+			block := merkleTestBlock(n)
+			for i := range block.Data {
+				proof, err := block.TxProof(block.Data[i].Hash())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !VerifyTxProof(block.BlockHeader, proof) {
+					t.Fatalf("proof of transaction %d does not verify", i)
+				}
+			}
+		})
+	}
+}
+
+func TestTxProofRejects(t *testing.T) {
+	block := merkleTestBlock(5)
+	outsider := Transaction{From: "sender", To: "recipient", Amount: 100, Nonce: 100}
+	tests := []struct {
+		name   string
+		header func() BlockHeader
+		proof  func(p *MerkleProof)
+	}{
+		{name: "other transaction", proof: func(p *MerkleProof) { p.TxHash = outsider.Hash() }},
+		{name: "tampered step", proof: func(p *MerkleProof) { p.Steps[0].Hash = outsider.Hash() }},
+		{name: "flipped side", proof: func(p *MerkleProof) { p.Steps[0].Left = !p.Steps[0].Left }},
+		{name: "missing step", proof: func(p *MerkleProof) { p.Steps = p.Steps[:len(p.Steps)-1] }},
+		{name: "extra step", proof: func(p *MerkleProof) { p.Steps = append(p.Steps, MerkleStep{Hash: outsider.Hash()}) }},
+		{name: "invalid hex", proof: func(p *MerkleProof) { p.Steps[0].Hash = "zz" }},
+		{name: "invalid leaf", proof: func(p *MerkleProof) { p.TxHash = "zz" }},
+		{name: "other header", header: func() BlockHeader { return merkleTestBlock(4).BlockHeader }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof, err := block.TxProof(block.Data[2].Hash())
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := block.BlockHeader
+			if tt.header != nil {
+				header = tt.header()
+			}
+			if tt.proof != nil {
+				tt.proof(proof)
+			}
+			if VerifyTxProof(header, proof) {
+				t.Fatal("invalid proof verified")
+			}
+		})
+	}
+}
+
+func TestTxProofMissingTransaction(t *testing.T) {
+	outsider := Transaction{From: "sender", To: "recipient", Amount: 100, Nonce: 100}
+	for _, n := range []int{0, 3} {
+		if _, err := merkleTestBlock(n).TxProof(outsider.Hash()); err == nil {
+			t.Fatalf("built a proof for a transaction missing from a block of %d", n)
+		}
+	}
+}
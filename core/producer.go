@@ -0,0 +1,165 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ProducerConfig configures the block production loop.
+type ProducerConfig struct {
+	MaxBlockTxs int    // Maximum number of transactions per block
+	PrivateKey  string // Hex-encoded secp256k1 key of the local validator
+}
+
+// Broadcaster publishes locally produced blocks and votes to the network.
+type Broadcaster interface {
+	BroadcastBlock(block *Block)
+	BroadcastVote(vote *Vote)
+}
+
+// BlockProducer proposes a block in every slot for which the local validator is the leader and
+// casts the local validator's finality votes.
+type BlockProducer struct {
+	state         *State
+	mempool       *Mempool
+	config        ProducerConfig
+	address       string
+	broadcaster   Broadcaster
+	lastPrevote   int // Heights already voted on, also kept in the store so restarts never vote twice at a height
+	lastPrecommit int
+}
+
+// NewBlockProducer creates a producer for the validator owning config.PrivateKey.
+// Produced blocks and votes are handed to broadcaster, which may be nil.
+func NewBlockProducer(state *State, mempool *Mempool, config ProducerConfig, broadcaster Broadcaster) (*BlockProducer, error) {
+	if state.SlotDuration <= 0 {
+		return nil, fmt.Errorf("slot duration must be positive")
+	}
+	pubKey, err := PublicKeyFromPrivate(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	address, err := AddressFromPublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	lastPrevote, err := state.Store.GetLastVote(address, Prevote)
+	if err != nil {
+		return nil, err
+	}
+	lastPrecommit, err := state.Store.GetLastVote(address, Precommit)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockProducer{
+		state:         state,
+		mempool:       mempool,
+		config:        config,
+		address:       address,
+		broadcaster:   broadcaster,
+		lastPrevote:   lastPrevote,
+		lastPrecommit: lastPrecommit,
+	}, nil
+}
+
+// Address returns the local validator address.
+func (p *BlockProducer) Address() string {
+	return p.address
+}
+
+// Run produces blocks once per slot until ctx is cancelled.
+func (p *BlockProducer) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.state.SlotDuration)
+	defer ticker.Stop()
+	slog.Info("Block producer started", "validator", p.address, "slot", p.state.SlotDuration)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.produce(p.state.CurrentSlot())
+			p.vote()
+		}
+	}
+}
+
+// produce proposes a block for slot if the local validator is its leader.
+func (p *BlockProducer) produce(slot uint64) {
+	p.mempool.Prune()
+
+	parent := p.state.Blockchain.ForkChoice.Head()
+	// A late tick can repeat the slot of the head, which must never be signed twice
+	if slot <= parent.Slot {
+		return
+	}
+
+	if p.state.Blockchain.Consensus.SelectValidator(parent.Hash, slot) != p.address {
+		return
+	}
+
+	txs := p.mempool.Pending(p.config.MaxBlockTxs)
+	evidence := p.state.Evidence.Pending()
+	block, err := p.state.ProduceBlock(txs, evidence, slot, parent.Hash, p.config.PrivateKey)
+	if err != nil {
+		slog.Error("Failed to produce block", "slot", slot, "error", err)
+		return
+	}
+	p.mempool.Remove(block.Data)
+	// Evidence left out of the block was already applied or does not verify
+	p.state.Evidence.Remove(evidence)
+	slog.Info("Produced block", "slot", slot, "index", block.Index, "hash", block.Hash, "txs", len(block.Data))
+	if p.broadcaster != nil {
+		p.broadcaster.BroadcastBlock(block)
+	}
+}
+
+// vote prevotes the canonical head and precommits the highest canonical block that gathered a
+// prevote quorum, never voting twice at the same height.
+func (p *BlockProducer) vote() {
+	if p.state.Blockchain.Consensus.Stake(p.address) <= 0 {
+		return
+	}
+	head := p.state.Blockchain.ForkChoice.Head()
+	if head.Index > p.lastPrevote && p.castVote(Prevote, head) {
+		p.lastPrevote = head.Index
+	}
+	path := p.state.Blockchain.ForkChoice.CanonicalPath()
+	for i := len(path) - 1; i >= 0 && path[i].Index > p.lastPrecommit; i-- {
+		if p.state.Blockchain.Finality.HasQuorum(path[i].Hash, Prevote) {
+			if p.castVote(Precommit, path[i]) {
+				p.lastPrecommit = path[i].Index
+			}
+			return
+		}
+	}
+}
+
+// castVote stores the vote's height, then signs a vote for block, records it locally and broadcasts
+// it. It reports whether the height was stored, after which the validator must not vote at it again.
+func (p *BlockProducer) castVote(voteType VoteType, block *Block) bool {
+	// The height is stored before anything is signed, so a crash cannot lead to a second vote
+	if err := p.state.Store.StoreLastVote(p.address, voteType, block.Index); err != nil {
+		slog.Error("Failed to store last vote", "type", voteType, "height", block.Index, "error", err)
+		return false
+	}
+	vote := &Vote{
+		Type:      voteType,
+		BlockHash: block.Hash,
+		Height:    block.Index,
+		Validator: p.address,
+	}
+	if err := vote.Sign(p.config.PrivateKey); err != nil {
+		slog.Error("Failed to sign vote", "error", err)
+		return true
+	}
+	if err := p.state.AddVote(vote); err != nil {
+		slog.Error("Failed to record own vote", "type", voteType, "block", block.Hash, "error", err)
+		return true
+	}
+	if p.broadcaster != nil {
+		p.broadcaster.BroadcastVote(vote)
+	}
+	return true
+}
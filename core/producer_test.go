@@ -0,0 +1,40 @@
+package core
+
+import "testing"
+
+func TestRestartedProducerDoesNotVoteTwice(t *testing.T) {
+	validator, other := newTestKey(t), newTestKey(t)
+	s := newTestState(t, validator)
+	if err := s.AddGenesisValidator(other.addr, "device", other.pub, minStake); err != nil {
+		t.Fatal(err)
+	}
+	leader := validator
+	if s.Blockchain.Consensus.SelectValidator(s.Blockchain.Root.Block.Hash, 1) == other.addr {
+		leader = other
+	}
+	block := produce(t, s, leader, 1)
+
+	// Half the stake prevotes the head, which is not enough to precommit it
+	p, err := NewBlockProducer(s, NewMempool(s, 100, 0), ProducerConfig{PrivateKey: validator.priv}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.vote()
+	if votes := s.Blockchain.Finality.votes[block.Hash][Prevote]; votes[validator.addr] == nil {
+		t.Fatal("validator did not prevote the head")
+	}
+
+	// A producer on a restarted node remembers the height it already prevoted at
+	restarted := openTestState(t, copyStore(t, s.Store))
+	p, err = NewBlockProducer(restarted, NewMempool(restarted, 100, 0), ProducerConfig{PrivateKey: validator.priv}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.lastPrevote != block.Index || p.lastPrecommit != 0 {
+		t.Fatalf("restored last votes %d/%d, want %d/0", p.lastPrevote, p.lastPrecommit, block.Index)
+	}
+	p.vote()
+	if votes := restarted.Blockchain.Finality.votes[block.Hash]; len(votes) != 0 {
+		t.Fatalf("restarted producer voted again at height %d", block.Index)
+	}
+}
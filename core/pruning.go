@@ -0,0 +1,82 @@
+package core
+
+import "log/slog"
+
+const defaultPruneDepth = 100 // Blocks below the canonical head whose side branches are kept by default
+
+// PruneConfig controls which blocks a node keeps once they are settled. Side branches forking below
+// the finalized head can never become canonical; with a depth set, branches forking deeper than
+// that below the canonical head are given up as well. If the store has a block archive, the bodies
+// of canonical blocks below that point are moved into it.
+type PruneConfig struct {
+	Archive bool // Archive node: keep every block and body
+	Depth   int  // Keep side branches forking within this many blocks of the head; 0 keeps them until finality
+}
+
+// DefaultPruneConfig returns the pruning policy used unless the node is configured otherwise.
+func DefaultPruneConfig() PruneConfig {
+	return PruneConfig{Depth: defaultPruneDepth}
+}
+
+// pruneHeight returns the height below which canonical blocks are settled under the policy.
+// The caller must hold the blockchain mutex.
+func (s *State) pruneHeight() int {
+	height := s.Blockchain.Finality.FinalizedHead().Index
+	if head := s.Blockchain.ForkChoice.Head().Index; s.Pruning.Depth > 0 && head-s.Pruning.Depth > height {
+		height = head - s.Pruning.Depth
+	}
+	return height
+}
+
+// pruneLocked settles the canonical blocks below the prune height since the last call: their undo
+// journals and the proposals recorded for their slots are dropped and the fork choice no longer
+// looks below them. Unless the node is an archive node, their side branches are removed as well and
+// their bodies archived if the store has a block archive.
+// The caller must hold both the blockchain mutex and the state mutex.
+func (s *State) pruneLocked() {
+	path := s.Blockchain.ForkChoice.CanonicalPath()
+	height := s.pruneHeight()
+	if s.prunedHeight >= height {
+		return
+	}
+	for ; s.prunedHeight < height; s.prunedHeight++ {
+		block, next := path[s.prunedHeight], path[s.prunedHeight+1]
+		// Reorganizations never reach below the pruned height, so the journal is dead weight
+		delete(s.journals, block.Hash)
+		if s.Pruning.Archive {
+			continue
+		}
+		node := s.Blockchain.Nodes[block.Hash]
+		for _, child := range node.Children {
+			if child != nil && child.Block.Hash != next.Hash {
+				s.dropBranchLocked(child.Block)
+			}
+		}
+		if s.Store.archive != nil && !block.Archived {
+			if err := s.Store.ArchiveBlock(block); err != nil {
+				slog.Error("Failed to archive block body", "block", block.Hash, "error", err)
+				continue
+			}
+			// Swap in a header-only copy; anything still holding the full block keeps it intact
+			node.Block = &Block{BlockHeader: block.BlockHeader, Hash: block.Hash, Signature: block.Signature, Archived: true}
+		}
+	}
+	settled := s.Blockchain.Nodes[path[s.prunedHeight].Hash]
+	s.Blockchain.settled = settled
+	for key := range s.proposals {
+		if key.slot <= settled.Block.Slot {
+			delete(s.proposals, key)
+		}
+	}
+}
+
+// dropBranchLocked removes an abandoned block and all its descendants from the tree and store.
+func (s *State) dropBranchLocked(block *Block) {
+	removed := s.Blockchain.detach(block.Hash)
+	for _, b := range removed {
+		if err := s.Store.DeleteBlock(b); err != nil {
+			slog.Error("Failed to delete pruned block", "block", b.Hash, "error", err)
+		}
+	}
+	slog.Info("Pruned abandoned branch", "root", block.Hash, "index", block.Index, "blocks", len(removed))
+}
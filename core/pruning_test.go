@@ -0,0 +1,93 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestArchivedBlocks(t *testing.T) {
+	validator, recipient := newTestKey(t), newTestKey(t)
+	s := newTestState(t, validator)
+	archive, err := OpenBlockArchive(filepath.Join(t.TempDir(), "blocks.archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	s.Store.SetArchive(archive)
+	s.Pruning = PruneConfig{Depth: 2}
+
+	register := produce(t, s, validator, 1, registerTx(t, recipient))
+	for slot := uint64(2); slot <= 5; slot++ {
+		produce(t, s, validator, slot)
+	}
+
+	node := s.Blockchain.Nodes[register.Hash]
+	if !node.Block.Archived || node.Block.Data != nil {
+		t.Fatal("settled block body was not archived")
+	}
+	if !s.ValidateBlockchain() {
+		t.Fatal("tree with archived blocks does not validate")
+	}
+	stored, err := s.Store.GetBlock(register.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Archived || !stored.verifyBody() || len(stored.Data) != 1 {
+		t.Fatal("archived body was not restored")
+	}
+
+	// A restart reads the archived bodies back to replay them
+	restarted := openTestState(t, s.Store)
+	assertSameState(t, restarted, s)
+}
+
+func TestArchiveNodeDropsJournals(t *testing.T) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	s.Pruning = PruneConfig{Archive: true, Depth: 2}
+
+	var blocks []*Block
+	for slot := uint64(1); slot <= 10; slot++ {
+		blocks = append(blocks, produce(t, s, validator, slot))
+	}
+	// Only the head and the blocks within the prune depth below it can still be reorganized
+	if len(s.journals) > s.Pruning.Depth+1 || len(s.proposals) > s.Pruning.Depth+1 {
+		t.Fatalf("%d journals and %d proposals kept with a prune depth of %d", len(s.journals), len(s.proposals), s.Pruning.Depth)
+	}
+	for _, block := range blocks {
+		node, exists := s.Blockchain.Nodes[block.Hash]
+		if !exists || node.Block.Archived {
+			t.Fatalf("archive node did not keep block %d with its body", block.Index)
+		}
+	}
+}
+
+func TestArchiveNodeRestartKeepsSideBranches(t *testing.T) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	config := DefaultStateConfig()
+	config.SlotDuration = s.SlotDuration
+	config.Pruning = PruneConfig{Archive: true, Depth: 2}
+	s.Pruning = config.Pruning
+
+	// Two competing blocks at height one, then a canonical chain past the default prune depth
+	other := openTestState(t, copyStore(t, s.Store))
+	first := produce(t, s, validator, 1)
+	importBlocks(t, s, growBranch(t, other, validator, 1, 1, newTestKey(t)))
+	for slot := uint64(2); slot <= uint64(DefaultPruneConfig().Depth)+3; slot++ {
+		produce(t, s, validator, slot)
+	}
+	side := first
+	if s.Blockchain.ForkChoice.IsCanonical(first) {
+		side = other.Blockchain.ForkChoice.Head()
+	}
+
+	restarted := openTestStateWith(t, s.Store, config)
+	assertSameState(t, restarted, s)
+	if _, exists := restarted.Blockchain.Nodes[side.Hash]; !exists {
+		t.Fatal("archive node pruned a side branch on restart")
+	}
+	if _, err := restarted.Store.GetBlock(side.Hash); err != nil {
+		t.Fatalf("archive node deleted a side block on restart: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// stateJournal records what a block changed so it can be rolled back: the account values it
+// overwrote (nil if the account did not exist), the offences it slashed, the minted supply and PoC
+// pool before it and, for blocks ending an epoch, the validator set it replaced.
+type stateJournal struct {
+	accounts   map[string]*UserData
+	evidence   []string
+	minted     int64
+	pocPool    int64
+	validators *ValidatorSet
+}
+
+// ReorgEvent describes a switch of the canonical head to a different branch of the triad tree.
+type ReorgEvent struct {
+	OldHead        string   `json:"oldHead"`
+	NewHead        string   `json:"newHead"`
+	CommonAncestor string   `json:"commonAncestor"`
+	Reverted       []string `json:"reverted"` // Blocks rolled back, newest first
+	Applied        []string `json:"applied"`  // Blocks replayed, oldest first
+}
+
+// SubscribeReorgs returns a channel receiving every subsequent reorganization. Events are dropped
+// for subscribers that fall behind.
+func (s *State) SubscribeReorgs() <-chan ReorgEvent {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	ch := make(chan ReorgEvent, 16)
+	s.reorgSubscribers = append(s.reorgSubscribers, ch)
+	return ch
+}
+
+// UnsubscribeReorgs stops delivery to a channel returned by SubscribeReorgs and closes it.
+func (s *State) UnsubscribeReorgs(sub <-chan ReorgEvent) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	for i, ch := range s.reorgSubscribers {
+		if ch == sub {
+			s.reorgSubscribers = append(s.reorgSubscribers[:i], s.reorgSubscribers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// applyBlockLocked executes a block's transactions, journaling the accounts it overwrites.
+// The caller must hold the mutex.
+func (s *State) applyBlockLocked(block *Block) error {
+	e, err := s.executeBlockLocked(block)
+	if err != nil {
+		return err
+	}
+	return s.commitBlockLocked(block, e)
+}
+
+// commitBlockLocked commits a block's execution, journaling the accounts it overwrites, and
+// indexes its transactions. The caller must hold the mutex.
+func (s *State) commitBlockLocked(block *Block, e *execution) error {
+	s.journals[block.Hash] = s.commitLocked(e)
+	for _, tx := range block.Data {
+		if err := s.Store.StoreTransaction(tx, block.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revertBlockLocked restores the accounts a block changed from its journal.
+// The caller must hold the mutex.
+func (s *State) revertBlockLocked(block *Block) error {
+	journal, exists := s.journals[block.Hash]
+	if !exists {
+		return fmt.Errorf("no undo journal for block %s", block.Hash)
+	}
+	for address, prev := range journal.accounts {
+		s.stateTree = setAccount(s.stateTree, address, prev)
+		if current, exists := s.Users[address]; exists {
+			s.indexDelegationsLocked(address, &current, prev)
+		}
+		if prev == nil {
+			delete(s.Users, address)
+			s.Blockchain.Consensus.SetJailed(address, false)
+		} else {
+			s.Users[address] = *prev
+			s.Blockchain.Consensus.SetJailed(address, prev.JailedUntil > 0)
+		}
+	}
+	for _, offence := range journal.evidence {
+		delete(s.slashedEvidence, offence)
+	}
+	if journal.validators != nil {
+		s.Blockchain.Consensus.SetValidators(journal.validators)
+	}
+	s.minted, s.pocPool = journal.minted, journal.pocPool
+	delete(s.journals, block.Hash)
+	for _, tx := range block.Data {
+		if err := s.Store.RemoveTransaction(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// followHeadLocked moves the state from oldHead to the fork choice head. Branches containing
+// blocks whose transactions do not apply are removed from the tree and the head is re-selected.
+// The caller must hold both the blockchain mutex and the state mutex.
+func (s *State) followHeadLocked(oldHead *Block) {
+	for {
+		newHead := s.Blockchain.ForkChoice.Head()
+		if newHead.Hash == oldHead.Hash {
+			return
+		}
+		bad, err := s.reorgLocked(oldHead, newHead)
+		if err == nil {
+			return
+		}
+		slog.Error("Chain reorganization failed", "oldHead", oldHead.Hash, "newHead", newHead.Hash, "error", err)
+		if bad == nil {
+			return
+		}
+		s.discardBranchLocked(bad)
+		s.Blockchain.ForkChoice.Update(s.Blockchain)
+	}
+}
+
+// reorgLocked rolls the state back from oldHead to the common ancestor with newHead and replays
+// the new branch. If a new block fails to apply, the old branch is restored and the failing block
+// is returned. The caller must hold both the blockchain mutex and the state mutex.
+func (s *State) reorgLocked(oldHead, newHead *Block) (*Block, error) {
+	// Each branch is ordered from the head down
+	ancestorNode, oldBranch, newBranch, err := s.Blockchain.fork(oldHead.Hash, newHead.Hash)
+	if err != nil {
+		return nil, err
+	}
+	ancestor := ancestorNode.Block
+
+	for i, block := range oldBranch {
+		if err := s.revertBlockLocked(block); err != nil {
+			s.replayLocked(oldBranch[:i])
+			return nil, err
+		}
+	}
+	for i := len(newBranch) - 1; i >= 0; i-- {
+		if err := s.applyBlockLocked(newBranch[i]); err != nil {
+			for _, applied := range newBranch[i+1:] {
+				s.revertBlockLocked(applied)
+			}
+			s.replayLocked(oldBranch)
+			return newBranch[i], err
+		}
+	}
+
+	event := ReorgEvent{
+		OldHead:        oldHead.Hash,
+		NewHead:        newHead.Hash,
+		CommonAncestor: ancestor.Hash,
+	}
+	for _, block := range oldBranch {
+		event.Reverted = append(event.Reverted, block.Hash)
+	}
+	for i := len(newBranch) - 1; i >= 0; i-- {
+		event.Applied = append(event.Applied, newBranch[i].Hash)
+	}
+	slog.Info("Chain reorganization", "oldHead", event.OldHead, "newHead", event.NewHead,
+		"ancestor", event.CommonAncestor, "reverted", len(event.Reverted), "applied", len(event.Applied))
+	for _, ch := range s.reorgSubscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil, nil
+}
+
+// replayLocked re-applies previously reverted blocks, given newest first.
+func (s *State) replayLocked(branch []*Block) {
+	for i := len(branch) - 1; i >= 0; i-- {
+		if err := s.applyBlockLocked(branch[i]); err != nil {
+			slog.Error("Failed to restore block after aborted reorganization", "block", branch[i].Hash, "error", err)
+		}
+	}
+}
+
+// discardBranchLocked removes an invalid block and all its descendants from the tree and store.
+func (s *State) discardBranchLocked(block *Block) {
+	for _, removed := range s.Blockchain.detach(block.Hash) {
+		if err := s.Store.DeleteBlock(removed); err != nil {
+			slog.Error("Failed to delete invalid block", "block", removed.Hash, "error", err)
+		}
+	}
+}
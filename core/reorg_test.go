@@ -0,0 +1,78 @@
+package core
+
+import "testing"
+
+// growBranch produces n blocks on the state's head from slot onwards, registering account in the
+// first of them, and returns the blocks.
+func growBranch(t *testing.T, s *State, validator testKey, slot uint64, n int, account testKey) []*Block {
+	t.Helper()
+	var blocks []*Block
+	for i := 0; i < n; i++ {
+		var txs []Transaction
+		if i == 0 {
+			txs = append(txs, registerTx(t, account))
+		}
+		blocks = append(blocks, produce(t, s, validator, slot+uint64(i), txs...))
+	}
+	return blocks
+}
+
+// importBlocks adds blocks produced elsewhere to the state.
+func importBlocks(t *testing.T, s *State, blocks []*Block) {
+	t.Helper()
+	for _, block := range blocks {
+		setSlot(s, block.Slot)
+		if err := s.AddBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReorgRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		common int // Blocks both branches share
+		first  int // Blocks of the first branch
+		second int // Blocks of the competing branch, which takes over
+		back   int // Blocks extending the first branch until it wins again
+	}{
+		{name: "fork at genesis", common: 0, first: 2, second: 3, back: 2},
+		{name: "fork above genesis", common: 2, first: 1, second: 2, back: 2},
+		{name: "deep fork", common: 1, first: 4, second: 6, back: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := newTestKey(t)
+			node := newTestState(t, validator)
+			slot := uint64(1)
+			for i := 0; i < tt.common; i++ {
+				produce(t, node, validator, slot)
+				slot++
+			}
+			forkStore := copyStore(t, node.Store)
+
+			growBranch(t, node, validator, slot, tt.first, newTestKey(t))
+			slot += uint64(tt.first)
+			first := openTestState(t, copyStore(t, node.Store))
+			firstHead := node.Blockchain.ForkChoice.Head()
+
+			// A node that only saw the competing branch ends up in the state the reorg must reach
+			second := openTestState(t, forkStore)
+			blocks := growBranch(t, second, validator, slot, tt.second, newTestKey(t))
+			slot += uint64(tt.second)
+			importBlocks(t, node, blocks)
+			assertSameState(t, node, second)
+
+			// The first branch overtakes again and the reorg is undone
+			blocks = growBranch(t, first, validator, slot, tt.back, newTestKey(t))
+			importBlocks(t, node, blocks)
+			assertSameState(t, node, first)
+			if !node.Blockchain.isAncestor(firstHead.Hash, node.Blockchain.Nodes[node.Blockchain.ForkChoice.Head().Hash]) {
+				t.Fatal("head is not on the first branch")
+			}
+			if !node.ValidateBlockchain() {
+				t.Fatal("tree does not validate after the round trip")
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const (
+	slashPercent = 10  // Share of the offender's stake burned per offence
+	jailBlocks   = 100 // Blocks an offender is excluded from consensus
+)
+
+// EvidenceType identifies the kind of misbehaviour proven by Evidence.
+type EvidenceType string
+
+const (
+	EvidenceDoubleSign EvidenceType = "double_sign" // Two different blocks signed for the same slot
+	EvidenceDoubleVote EvidenceType = "double_vote" // Two different votes of one type at the same height
+	EvidenceInvalidPoC EvidenceType = "invalid_poc" // A signed PoC claim flagged by DetectCheat
+)
+
+// PoCClaim is a contributor's signed proof-of-contribution report.
+type PoCClaim struct {
+	Address      string
+	Contribution PoCContribution
+	Timestamp    int64
+	Signature    string
+}
+
+// payloadHash returns the digest covered by the claim signature.
+func (c *PoCClaim) payloadHash() []byte {
+	data, _ := json.Marshal(struct {
+		Address      string
+		Contribution PoCContribution
+		Timestamp    int64
+	}{c.Address, c.Contribution, c.Timestamp})
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// Sign signs the claim with the contributor's hex-encoded secp256k1 private key.
+func (c *PoCClaim) Sign(privateKey string) error {
+	sig, err := signHash(privateKey, c.payloadHash())
+	if err != nil {
+		return fmt.Errorf("failed to sign claim: %v", err)
+	}
+	c.Signature = sig
+	return nil
+}
+
+// Evidence proves that Offender misbehaved. Blocks, Votes or Claim is set according to Type.
+type Evidence struct {
+	Type     EvidenceType
+	Offender string
+	Blocks   [2]*Block `json:",omitempty"`
+	Votes    [2]*Vote  `json:",omitempty"`
+	Claim    *PoCClaim `json:",omitempty"`
+}
+
+// Hash returns the hex-encoded sha256 of the evidence.
+func (ev *Evidence) Hash() string {
+	data, _ := json.Marshal(ev)
+	hash := sha256.Sum256(data)
+	return fmt.Sprintf("%x", hash)
+}
+
+// offence returns the key of the misbehaviour the evidence proves: the offender's slot for a double
+// sign, its vote type and height for a double vote, and the claim for an invalid PoC. Every pair of
+// conflicting blocks or votes, in either order, proves the same offence, which is slashed only once.
+func (ev *Evidence) offence() string {
+	switch ev.Type {
+	case EvidenceDoubleSign:
+		if ev.Blocks[0] != nil {
+			return fmt.Sprintf("%s:%s:%d", ev.Type, ev.Offender, ev.Blocks[0].Slot)
+		}
+	case EvidenceDoubleVote:
+		if ev.Votes[0] != nil {
+			return fmt.Sprintf("%s:%s:%s:%d", ev.Type, ev.Offender, ev.Votes[0].Type, ev.Votes[0].Height)
+		}
+	case EvidenceInvalidPoC:
+		if ev.Claim != nil {
+			return fmt.Sprintf("%s:%s:%x", ev.Type, ev.Offender, ev.Claim.payloadHash())
+		}
+	}
+	return fmt.Sprintf("%s:%s:%s", ev.Type, ev.Offender, ev.Hash())
+}
+
+// Verify checks the evidence against the offender's registered public key.
+func (ev *Evidence) Verify(consensus *Consensus) error {
+	pubKey, exists := consensus.PublicKey(ev.Offender)
+	if !exists {
+		return fmt.Errorf("no registered key for %s", ev.Offender)
+	}
+	switch ev.Type {
+	case EvidenceDoubleSign:
+		a, b := ev.Blocks[0], ev.Blocks[1]
+		if a == nil || b == nil || a.Hash == b.Hash || a.Slot != b.Slot {
+			return fmt.Errorf("blocks are not a double sign")
+		}
+		if a.Validator != ev.Offender || b.Validator != ev.Offender || !a.VerifySignature(pubKey) || !b.VerifySignature(pubKey) {
+			return fmt.Errorf("blocks are not signed by %s", ev.Offender)
+		}
+	case EvidenceDoubleVote:
+		a, b := ev.Votes[0], ev.Votes[1]
+		if a == nil || b == nil || a.Type != b.Type || a.Height != b.Height || a.BlockHash == b.BlockHash {
+			return fmt.Errorf("votes are not a double vote")
+		}
+		if a.Validator != ev.Offender || b.Validator != ev.Offender || !a.VerifySignature(pubKey) || !b.VerifySignature(pubKey) {
+			return fmt.Errorf("votes are not signed by %s", ev.Offender)
+		}
+	case EvidenceInvalidPoC:
+		claim := ev.Claim
+		if claim == nil || claim.Address != ev.Offender || !verifyHash(pubKey, claim.payloadHash(), claim.Signature) {
+			return fmt.Errorf("claim is not signed by %s", ev.Offender)
+		}
+		if !DetectCheat(claim.Contribution.Computations) {
+			return fmt.Errorf("claim is not flagged as cheating")
+		}
+	default:
+		return fmt.Errorf("unknown evidence type %q", ev.Type)
+	}
+	return nil
+}
+
+// EquivocationError is returned when a validator is caught signing conflicting messages.
+type EquivocationError struct {
+	Evidence *Evidence
+}
+
+func (e *EquivocationError) Error() string {
+	return fmt.Sprintf("%s by %s", e.Evidence.Type, e.Evidence.Offender)
+}
+
+// EvidencePool holds verified evidence waiting for block inclusion.
+type EvidencePool struct {
+	evidence map[string]*Evidence
+	mutex    sync.Mutex
+}
+
+// NewEvidencePool creates an empty evidence pool.
+func NewEvidencePool() *EvidencePool {
+	return &EvidencePool{evidence: make(map[string]*Evidence)}
+}
+
+// Add queues evidence for inclusion, unless evidence of the same offence is already queued.
+func (p *EvidencePool) Add(ev *Evidence) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, exists := p.evidence[ev.offence()]; !exists {
+		p.evidence[ev.offence()] = ev
+	}
+}
+
+// Pending returns all queued evidence.
+func (p *EvidencePool) Pending() []*Evidence {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	pending := make([]*Evidence, 0, len(p.evidence))
+	for _, ev := range p.evidence {
+		pending = append(pending, ev)
+	}
+	return pending
+}
+
+// Remove drops evidence that was included in a block or turned out to be unusable.
+func (p *EvidencePool) Remove(evidence []*Evidence) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, ev := range evidence {
+		delete(p.evidence, ev.offence())
+	}
+}
+
+// applyEvidence verifies evidence and slashes the offender. Each offence is only slashed once,
+// whichever evidence of it comes first.
+func (e *execution) applyEvidence(ev *Evidence) error {
+	offence := ev.offence()
+	if _, exists := e.state.slashedEvidence[offence]; exists {
+		return fmt.Errorf("offence %s already slashed", offence)
+	}
+	for _, applied := range e.evidence {
+		if applied == offence {
+			return fmt.Errorf("offence %s already slashed", offence)
+		}
+	}
+	if err := ev.Verify(e.state.Blockchain.Consensus); err != nil {
+		return err
+	}
+	if err := e.slash(ev.Offender); err != nil {
+		return err
+	}
+	e.evidence = append(e.evidence, offence)
+	return nil
+}
+
+// slash burns a share of an offender's bonded and unbonding stake, or of its balance if nothing is
+// staked, and the same share of the stake delegated to it. The offender is jailed, which takes it
+// out of consensus as soon as the block commits and out of validator sets snapshotted until the
+// jail period ends.
+func (e *execution) slash(address string) error {
+	offender, exists := e.account(address)
+	if !exists {
+		return fmt.Errorf("offender %s not found", address)
+	}
+	staked := offender.Bonded
+	for _, entry := range offender.Unbonding {
+		if entry.Validator == "" {
+			staked += entry.Amount
+		}
+	}
+	if staked > 0 {
+		offender.Bonded -= offender.Bonded * slashPercent / 100
+		offender.Unbonding = slashUnbonding(offender.Unbonding, "")
+	} else {
+		offender.Balance -= offender.Balance * slashPercent / 100
+	}
+	delegated := int64(0)
+	for _, delegatorAddress := range e.delegators(address) {
+		delegator, _ := e.account(delegatorAddress)
+		delegator = delegator.clone()
+		if delegator.Delegations[address] > 0 {
+			delegator.Delegations[address] -= delegator.Delegations[address] * slashPercent / 100
+			delegated += delegator.Delegations[address]
+		}
+		delegator.Unbonding = slashUnbonding(delegator.Unbonding, address)
+		e.updates[delegatorAddress] = delegator
+	}
+	offender.Delegated = delegated
+	offender.JailedUntil = e.height + jailBlocks
+	if offender.Reputation != nil {
+		rep := *offender.Reputation
+		rep.CheatAttempts++
+		offender.Reputation = &rep
+	}
+	e.updates[address] = offender
+	return nil
+}
+
+// slashUnbonding returns a copy of entries with the ones unbonding from validator cut by the slash share.
+func slashUnbonding(entries []Unbonding, validator string) []Unbonding {
+	slashed := make([]Unbonding, len(entries))
+	for i, entry := range entries {
+		if entry.Validator == validator {
+			entry.Amount -= entry.Amount * slashPercent / 100
+		}
+		slashed[i] = entry
+	}
+	return slashed
+}
+
+// releaseJailed lets offenders whose jail period has ended back into consensus.
+func (e *execution) releaseJailed() {
+	for address, user := range e.state.Users {
+		if user.JailedUntil > 0 && user.JailedUntil <= e.height {
+			user.JailedUntil = 0
+			e.updates[address] = user
+		}
+	}
+}
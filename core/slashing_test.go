@@ -0,0 +1,47 @@
+package core
+
+import "testing"
+
+func TestDoubleSignSlashedOnce(t *testing.T) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	// Three blocks the validator signed for the same slot
+	var blocks []*Block
+	for _, parent := range []string{"a", "b", "c"} {
+		block := NewBlock(1, 1, nil, parent, validator.addr)
+		block.seal()
+		if err := block.SignBlock(validator.priv); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block)
+	}
+	double := func(a, b *Block) *Evidence {
+		return &Evidence{Type: EvidenceDoubleSign, Offender: validator.addr, Blocks: [2]*Block{a, b}}
+	}
+	evidence := []*Evidence{
+		double(blocks[0], blocks[1]),
+		double(blocks[1], blocks[0]),
+		double(blocks[0], blocks[2]),
+	}
+
+	setSlot(s, 1)
+	block, err := s.ProduceBlock(nil, evidence, 1, s.Blockchain.ForkChoice.Head().Hash, validator.priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Evidence) != 1 {
+		t.Fatalf("block includes %d pieces of evidence for one offence", len(block.Evidence))
+	}
+	if user, _ := s.GetData(validator.addr); user.Bonded != minStake-minStake*slashPercent/100 {
+		t.Fatalf("bonded %d after one slash of %d", user.Bonded, minStake)
+	}
+
+	// The pool holds one piece of evidence per offence
+	pool := NewEvidencePool()
+	for _, ev := range evidence {
+		pool.Add(ev)
+	}
+	if pending := pool.Pending(); len(pending) != 1 {
+		t.Fatalf("pool holds %d pieces of evidence for one offence", len(pending))
+	}
+}
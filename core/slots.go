@@ -0,0 +1,38 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultSlotDuration = 5 * time.Second
+	maxSlotDrift        = 1 // Slots a block may run ahead of the local clock, absorbing clock skew
+)
+
+// slotAt returns the slot containing a Unix nanosecond timestamp, counted from the genesis block.
+func (s *State) slotAt(timestamp int64) uint64 {
+	elapsed := timestamp - s.Blockchain.Root.Block.Timestamp
+	if elapsed < 0 {
+		return 0
+	}
+	return uint64(time.Duration(elapsed) / s.SlotDuration)
+}
+
+// CurrentSlot returns the slot number for the current time, counted from the genesis block.
+func (s *State) CurrentSlot() uint64 {
+	return s.slotAt(s.now().UnixNano())
+}
+
+// checkSlot checks that a block's slot is the one its timestamp falls in and is not ahead of the
+// local clock. Leaders are drawn from the parent hash and slot, so without this a validator could
+// try future slots until it wins one.
+func (s *State) checkSlot(block *Block) error {
+	if slot := s.slotAt(block.Timestamp); block.Slot != slot {
+		return fmt.Errorf("block slot %d does not match slot %d of its timestamp", block.Slot, slot)
+	}
+	if current := s.CurrentSlot(); block.Slot > current+maxSlotDrift {
+		return fmt.Errorf("block slot %d is ahead of current slot %d", block.Slot, current)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlotChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		slot    uint64
+		clock   uint64 // Slot of the local clock
+		stamp   uint64 // Slot of the block timestamp
+		wantErr string
+	}{
+		{name: "current slot", slot: 5, clock: 5, stamp: 5},
+		{name: "within drift", slot: 6, clock: 5, stamp: 6},
+		{name: "ahead of clock", slot: 7, clock: 5, stamp: 7, wantErr: "ahead of current slot"},
+		{name: "timestamp mismatch", slot: 5, clock: 5, stamp: 4, wantErr: "does not match slot"},
+		{name: "not after parent", slot: 1, clock: 5, stamp: 1, wantErr: "not after parent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := newTestKey(t)
+			s := newTestState(t, validator)
+			// Without emission an empty block leaves the state root unchanged
+			s.Emission = EmissionSchedule{}
+			produce(t, s, validator, 1)
+
+			setSlot(s, tt.stamp)
+			stamp := s.now()
+			setSlot(s, tt.clock)
+			block := NewBlock(2, tt.slot, nil, s.Blockchain.ForkChoice.Head().Hash, validator.addr)
+			block.Timestamp = stamp.UnixNano()
+			block.ValidatorSetHash = s.Blockchain.Consensus.ValidatorSet().Hash()
+			block.StateRoot = s.Blockchain.ForkChoice.Head().StateRoot
+			block.seal()
+			if err := block.SignBlock(validator.priv); err != nil {
+				t.Fatal(err)
+			}
+			err := s.AddBlock(block)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
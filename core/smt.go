@@ -0,0 +1,179 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// The state commitment is a compact sparse Merkle tree keyed by sha256(address): a subtree holding
+// a single account collapses into its leaf, so only the levels where keys diverge are hashed.
+// Empty subtrees hash to 32 zero bytes. The state keeps the tree of the canonical head and each
+// block only rehashes the paths to the accounts it changes.
+
+// smtLeaf is one account in the state tree.
+type smtLeaf struct {
+	key   []byte // sha256 of the address
+	value []byte // sha256 of the encoded account
+}
+
+// AccountProof proves an account's data against the state root in a block header.
+type AccountProof struct {
+	Address  string   `json:"address"`
+	Account  UserData `json:"account"`
+	Siblings []string `json:"siblings"` // Sibling subtree hashes from the root down to the account's leaf
+}
+
+// smtKey returns the tree key of an address.
+func smtKey(address string) []byte {
+	key := sha256.Sum256([]byte(address))
+	return key[:]
+}
+
+// accountHash returns the digest of an account's data committed in the state tree.
+func accountHash(user UserData) []byte {
+	data, _ := json.Marshal(user)
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// smtBit returns the bit of key at depth, most significant first.
+func smtBit(key []byte, depth int) int {
+	return int(key[depth/8]>>(7-uint(depth%8))) & 1
+}
+
+// smtLeafHash hashes a leaf, binding the account digest to its key.
+func smtLeafHash(leaf smtLeaf) []byte {
+	data := append([]byte{merkleLeafPrefix}, leaf.key...)
+	hash := sha256.Sum256(append(data, leaf.value...))
+	return hash[:]
+}
+
+// smtNode is a node of the state tree: a leaf holding one account, or an inner node with at least
+// two accounts below it. Nodes are never modified once built, so a tree with a few accounts changed
+// shares every other subtree with the tree it was derived from. The nil node is the empty subtree.
+type smtNode struct {
+	hash        []byte
+	leaf        *smtLeaf // Set on leaves
+	left, right *smtNode
+}
+
+// rootHash returns the hash of the subtree rooted at n.
+func (n *smtNode) rootHash() []byte {
+	if n == nil {
+		return make([]byte, sha256.Size)
+	}
+	return n.hash
+}
+
+// newSMTInner returns the subtree with the given children, collapsed into its leaf if it holds a single account.
+func newSMTInner(left, right *smtNode) *smtNode {
+	switch {
+	case left == nil && (right == nil || right.leaf != nil):
+		return right
+	case right == nil && left.leaf != nil:
+		return left
+	}
+	return &smtNode{hash: merkleNode(left.rootHash(), right.rootHash()), left: left, right: right}
+}
+
+// smtUpdate returns the subtree n at depth with the account at key set to value, or removed if
+// value is nil. Only the nodes on the path to key are rebuilt.
+func smtUpdate(n *smtNode, key, value []byte, depth int) *smtNode {
+	left, right := (*smtNode)(nil), (*smtNode)(nil)
+	switch {
+	case n == nil || n.leaf != nil && bytes.Equal(n.leaf.key, key):
+		if value == nil {
+			return nil
+		}
+		leaf := smtLeaf{key: key, value: value}
+		return &smtNode{hash: smtLeafHash(leaf), leaf: &leaf}
+	case n.leaf != nil:
+		if value == nil {
+			return n
+		}
+		// Push the other account down a level so both can sit below an inner node
+		if smtBit(n.leaf.key, depth) == 0 {
+			left = n
+		} else {
+			right = n
+		}
+	default:
+		left, right = n.left, n.right
+	}
+	if smtBit(key, depth) == 0 {
+		left = smtUpdate(left, key, value, depth+1)
+	} else {
+		right = smtUpdate(right, key, value, depth+1)
+	}
+	return newSMTInner(left, right)
+}
+
+// setAccount updates a state tree with an account's data, or removes the account if user is nil.
+func setAccount(tree *smtNode, address string, user *UserData) *smtNode {
+	if user == nil {
+		return smtUpdate(tree, smtKey(address), nil, 0)
+	}
+	return smtUpdate(tree, smtKey(address), accountHash(*user), 0)
+}
+
+// stateRoot returns the hex-encoded root of the state tree as modified by the execution. The
+// updated tree is kept for the execution to be committed with.
+func (e *execution) stateRoot() string {
+	tree := e.state.stateTree
+	for address, user := range e.updates {
+		user := user
+		tree = setAccount(tree, address, &user)
+	}
+	e.tree = tree
+	return hex.EncodeToString(tree.rootHash())
+}
+
+// AccountProof returns an account's data with a proof against the canonical head, along with the
+// head's header for the light client to check the proof and the header's signature.
+func (s *State) AccountProof(address string) (*AccountProof, *Block, error) {
+	s.Blockchain.Mutex.Lock()
+	defer s.Blockchain.Mutex.Unlock()
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	user, exists := s.Users[address]
+	if !exists {
+		return nil, nil, fmt.Errorf("user %s not found", address)
+	}
+	proof := &AccountProof{Address: address, Account: user}
+	key := smtKey(address)
+	for node, depth := s.stateTree, 0; node.leaf == nil; depth++ {
+		if smtBit(key, depth) == 0 {
+			proof.Siblings = append(proof.Siblings, hex.EncodeToString(node.right.rootHash()))
+			node = node.left
+		} else {
+			proof.Siblings = append(proof.Siblings, hex.EncodeToString(node.left.rootHash()))
+			node = node.right
+		}
+	}
+	return proof, s.Blockchain.ForkChoice.Head(), nil
+}
+
+// VerifyAccountProof checks an account proof against a block header alone.
+func VerifyAccountProof(header BlockHeader, proof *AccountProof) bool {
+	key := smtKey(proof.Address)
+	if len(proof.Siblings) > len(key)*8 {
+		return false
+	}
+	current := smtLeafHash(smtLeaf{key: key, value: accountHash(proof.Account)})
+	for depth := len(proof.Siblings) - 1; depth >= 0; depth-- {
+		sibling, err := hex.DecodeString(proof.Siblings[depth])
+		if err != nil {
+			return false
+		}
+		if smtBit(key, depth) == 0 {
+			current = merkleNode(current, sibling)
+		} else {
+			current = merkleNode(sibling, current)
+		}
+	}
+	return hex.EncodeToString(current) == header.StateRoot
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// smtTestState returns a state whose head commits to the validator's account and n registered ones.
+func smtTestState(t *testing.T, n int) (*State, []string) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	addresses := []string{validator.addr}
+	var txs []Transaction
+	for i := 0; i < n; i++ {
+		key := newTestKey(t)
+		txs = append(txs, registerTx(t, key))
+		addresses = append(addresses, key.addr)
+	}
+	produce(t, s, validator, 1, txs...)
+	return s, addresses
+}
+
+func TestAccountProof(t *testing.T) {
+	for _, n := range []int{0, 1, 4, 16} {
+		t.Run(fmt.Sprintf("%d registered", n), func(t *testing.T) {
+			s, addresses := smtTestState(t, n)
+			for _, address := range addresses {
+				proof, head, err := s.AccountProof(address)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !VerifyAccountProof(head.BlockHeader, proof) {
+					t.Fatalf("proof of %s does not verify", address)
+				}
+			}
+		})
+	}
+}
+
+func TestAccountProofRejects(t *testing.T) {
+	s, addresses := smtTestState(t, 4)
+	tests := []struct {
+		name   string
+		header func(h *BlockHeader)
+		proof  func(p *AccountProof)
+	}{
+		{name: "tampered balance", proof: func(p *AccountProof) { p.Account.Balance += 1000 }},
+		{name: "tampered nonce", proof: func(p *AccountProof) { p.Account.LastNonce++ }},
+		{name: "other address", proof: func(p *AccountProof) { p.Address = addresses[2] }},
+		{name: "tampered sibling", proof: func(p *AccountProof) { p.Siblings[0] = p.Siblings[len(p.Siblings)-1] + "00" }},
+		{name: "missing sibling", proof: func(p *AccountProof) { p.Siblings = p.Siblings[1:] }},
+		{name: "extra sibling", proof: func(p *AccountProof) { p.Siblings = append(p.Siblings, p.Siblings[0]) }},
+		{name: "too many siblings", proof: func(p *AccountProof) { p.Siblings = make([]string, 257) }},
+		{name: "invalid hex", proof: func(p *AccountProof) { p.Siblings[0] = "zz" }},
+		{name: "other state root", header: func(h *BlockHeader) { h.StateRoot = h.TxRoot }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof, head, err := s.AccountProof(addresses[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := head.BlockHeader
+			if tt.header != nil {
+				tt.header(&header)
+			}
+			if tt.proof != nil {
+				tt.proof(proof)
+			}
+			if VerifyAccountProof(header, proof) {
+				t.Fatal("invalid proof verified")
+			}
+		})
+	}
+}
+
+func TestAccountProofUnknownAccount(t *testing.T) {
+	s, _ := smtTestState(t, 1)
+	if _, _, err := s.AccountProof(newTestKey(t).addr); err == nil {
+		t.Fatal("built a proof for an unknown account")
+	}
+}
+
+func TestStateTreeUpdates(t *testing.T) {
+	accounts := make(map[string]*UserData)
+	var tree *smtNode
+	for i := 0; i < 64; i++ {
+		address := fmt.Sprintf("account%d", i)
+		accounts[address] = &UserData{Balance: int64(i)}
+		tree = setAccount(tree, address, accounts[address])
+	}
+	// Remove every third account and change every fifth
+	for i := 0; i < 64; i++ {
+		address := fmt.Sprintf("account%d", i)
+		switch {
+		case i%3 == 0:
+			delete(accounts, address)
+			tree = setAccount(tree, address, nil)
+		case i%5 == 0:
+			accounts[address] = &UserData{Balance: int64(-i)}
+			tree = setAccount(tree, address, accounts[address])
+		}
+	}
+
+	var rebuilt *smtNode
+	for i := 63; i >= 0; i-- {
+		if user, exists := accounts[fmt.Sprintf("account%d", i)]; exists {
+			rebuilt = setAccount(rebuilt, fmt.Sprintf("account%d", i), user)
+		}
+	}
+	if hex.EncodeToString(tree.rootHash()) != hex.EncodeToString(rebuilt.rootHash()) {
+		t.Fatal("updated tree differs from a tree built from the remaining accounts")
+	}
+	for address := range accounts {
+		tree = setAccount(tree, address, nil)
+	}
+	if tree != nil {
+		t.Fatal("tree is not empty after removing every account")
+	}
+}
+
+func TestStateTreeFollowsReorg(t *testing.T) {
+	validator := newTestKey(t)
+	node := newTestState(t, validator)
+	forkStore := copyStore(t, node.Store)
+	growBranch(t, node, validator, 1, 2, newTestKey(t))
+
+	// Reverting the registration removes the account from the tree again
+	other := openTestState(t, forkStore)
+	importBlocks(t, node, growBranch(t, other, validator, 3, 3, newTestKey(t)))
+	if got, want := hex.EncodeToString(node.stateTree.rootHash()), node.Blockchain.ForkChoice.Head().StateRoot; got != want {
+		t.Fatalf("state tree root %s, head commits to %s", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import "fmt"
+
+const (
+	minStake        = 100 // Smallest bonded stake that makes an account a validator
+	unbondingBlocks = 50  // Blocks unstaked funds stay locked and slashable before they can be withdrawn
+)
+
+const (
+	TxStake    TxType = "stake"    // Bonds Amount of the sender's balance
+	TxUnstake  TxType = "unstake"  // Starts unbonding Amount of the sender's bonded stake
+	TxWithdraw TxType = "withdraw" // Returns every unbonded entry whose delay has passed to the balance
+)
+
+// Unbonding is stake that has been unstaked and is waiting out the unbonding delay.
+type Unbonding struct {
+	Amount        int64
+	ReleaseHeight int    // Height from which the amount can be withdrawn
+	Validator     string `json:",omitempty"` // Validator the amount was delegated to; empty for own stake
+}
+
+// applyStaking applies a stake, unstake or withdraw transaction to its sender's account.
+func (e *execution) applyStaking(user UserData, tx Transaction) error {
+	user = user.clone()
+	switch tx.Type {
+	case TxStake:
+		if user.Bonded+tx.Amount < minStake {
+			return fmt.Errorf("bonded stake %d below minimum %d", user.Bonded+tx.Amount, minStake)
+		}
+		user.Balance -= tx.Amount
+		user.Bonded += tx.Amount
+	case TxUnstake:
+		remaining := user.Bonded - tx.Amount
+		if remaining > 0 && remaining < minStake {
+			return fmt.Errorf("remaining stake %d below minimum %d", remaining, minStake)
+		}
+		user.Bonded = remaining
+		user.Unbonding = append(user.Unbonding, Unbonding{
+			Amount:        tx.Amount,
+			ReleaseHeight: e.height + unbondingBlocks,
+		})
+	case TxWithdraw:
+		var pending []Unbonding
+		released := int64(0)
+		for _, entry := range user.Unbonding {
+			if entry.ReleaseHeight <= e.height {
+				released += entry.Amount
+			} else {
+				pending = append(pending, entry)
+			}
+		}
+		if released == 0 {
+			return fmt.Errorf("no unbonded stake to withdraw")
+		}
+		user.Balance += released
+		user.Unbonding = pending
+	default:
+		return fmt.Errorf("unknown transaction type %q", tx.Type)
+	}
+	e.updates[tx.From] = user
+	return nil
+}
+
+// GenesisValidator is a validator bonded outside any block to bootstrap a chain. It is kept in the
+// store so a restarted node can seed it again before replaying the blocks built on it.
+type GenesisValidator struct {
+	Address   string
+	DeviceID  string
+	PublicKey string
+	Stake     int64
+	Height    int // Canonical head height when the validator was added
+}
+
+// AddGenesisValidator creates an account and bonds stake to it directly, so a new chain has a
+// validator to produce the blocks that carry everyone else's stake transactions. It is only
+// allowed for new accounts before the chain grows past genesis.
+func (s *State) AddGenesisValidator(address, deviceID, publicKey string, stake int64) error {
+	if stake < minStake {
+		return fmt.Errorf("genesis stake %d below minimum %d", stake, minStake)
+	}
+	s.Blockchain.Mutex.Lock()
+	defer s.Blockchain.Mutex.Unlock()
+	head := s.Blockchain.ForkChoice.Head()
+	if head.Index > 0 {
+		return fmt.Errorf("chain already grew past genesis")
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if _, exists := s.Users[address]; exists {
+		return fmt.Errorf("account %s already exists", address)
+	}
+	v := GenesisValidator{Address: address, DeviceID: deviceID, PublicKey: publicKey, Stake: stake, Height: head.Index}
+	if err := s.seedValidatorLocked(v, head.Hash); err != nil {
+		return err
+	}
+	return s.Store.StoreGenesisValidator(v)
+}
+
+// seedValidatorLocked creates a genesis validator's account with its stake bonded. Genesis
+// validators join the set in force on top of the head block immediately instead of waiting for
+// the next epoch. The caller must hold the state mutex.
+func (s *State) seedValidatorLocked(v GenesisValidator, head string) error {
+	if err := s.addUserLocked(v.Address, v.DeviceID, UserData{PublicKey: v.PublicKey}); err != nil {
+		return err
+	}
+	user := s.Users[v.Address]
+	user.Bonded = v.Stake
+	s.Users[v.Address] = user
+	s.stateTree = setAccount(s.stateTree, v.Address, &user)
+	set := s.newExecution(0).validatorSet(EpochOf(v.Height + 1))
+	s.Blockchain.Consensus.SetValidators(set)
+	s.seededSets[head] = set.Hash()
+	return nil
+}
@@ -0,0 +1,24 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddGenesisValidatorRejects(t *testing.T) {
+	validator, other := newTestKey(t), newTestKey(t)
+	s := newTestState(t, validator)
+	if err := s.AddGenesisValidator(validator.addr, "device", validator.pub, 1000); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("error %v, want an existing account rejected", err)
+	}
+
+	produce(t, s, validator, 1)
+	// Even with every validator jailed, nothing can be bonded outside a block once the chain grew
+	s.Blockchain.Consensus.SetJailed(validator.addr, true)
+	if err := s.AddGenesisValidator(other.addr, "device", other.pub, 1000); err == nil || !strings.Contains(err.Error(), "past genesis") {
+		t.Fatalf("error %v, want seeding past genesis rejected", err)
+	}
+	if user, _ := s.GetData(validator.addr); user.Bonded != minStake {
+		t.Fatalf("bonded %d, want %d", user.Bonded, minStake)
+	}
+}
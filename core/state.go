@@ -2,7 +2,9 @@ package core
 
 import (
 	"fmt"
+	"log/slog"
 	"sync"
+	"time"
 )
 
 // State manages the global state of the blockchain, users, and validators.
@@ -10,107 +12,373 @@ type State struct {
 	Users      map[string]UserData
 	Mutex      sync.Mutex
 	Blockchain *TriadBlockchain
+	Store      *Store
+
+	Evidence *EvidencePool    // Slashing evidence waiting for block inclusion
+	Emission EmissionSchedule // Token emission paid to block producers and PoC contributors
+	Pruning  PruneConfig      // Which blocks are kept once they are settled
+
+	SlotDuration time.Duration // Length of one production slot
+
+	journals         map[string]*stateJournal       // Block hash -> undo journal for applied blocks
+	stateTree        *smtNode                       // State tree committing to Users
+	slashedEvidence  map[string]struct{}            // Offences slashed on the canonical branch
+	minted           int64                          // Supply minted by the emission schedule on the canonical branch
+	pocPool          int64                          // Emission set aside for the next PoC payout
+	proposals        map[proposal]string            // Hash of the first block seen for each proposal
+	seededSets       map[string]string              // Block hash -> hash of the genesis validator set seeded on top of it
+	delegatorIndex   map[string]map[string]struct{} // Validator -> accounts staking with it, kept in step with Users
+	prunedHeight     int                            // Canonical blocks below this height are settled and have no undo journals
+	reorgSubscribers []chan ReorgEvent
+	now              func() time.Time // Clock that block slots are checked against
+}
+
+// StateConfig holds the node settings a state needs before it replays the chain.
+type StateConfig struct {
+	Emission     EmissionSchedule // Only used for a new chain; an existing chain keeps the schedule stored with it
+	Pruning      PruneConfig
+	SlotDuration time.Duration
+}
+
+// DefaultStateConfig returns the settings used unless the node is configured otherwise.
+func DefaultStateConfig() StateConfig {
+	return StateConfig{
+		Emission:     DefaultEmissionSchedule(),
+		Pruning:      DefaultPruneConfig(),
+		SlotDuration: defaultSlotDuration,
+	}
+}
+
+// NewState creates a state backed by store, restoring the triad tree and finalized head from it and
+// replaying the canonical chain under the chain's emission schedule to rebuild the accounts and
+// validator set. A new chain is fixed to the configured schedule. Blocks settled before the restart
+// are pruned again under the configured policy.
+func NewState(store *Store, config StateConfig) (*State, error) {
+	bc, err := LoadTriadBlockchain(store)
+	if err != nil {
+		return nil, err
+	}
+	emission, stored, err := store.GetEmission()
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		emission = config.Emission
+	} else if emission != config.Emission {
+		slog.Warn("Ignoring configured emission schedule for an existing chain", "schedule", emission)
+	}
+	s := &State{
+		Users:           make(map[string]UserData),
+		Blockchain:      bc,
+		Store:           store,
+		Evidence:        NewEvidencePool(),
+		Emission:        emission,
+		Pruning:         config.Pruning,
+		SlotDuration:    config.SlotDuration,
+		journals:        make(map[string]*stateJournal),
+		slashedEvidence: make(map[string]struct{}),
+		proposals:       make(map[proposal]string),
+		seededSets:      make(map[string]string),
+		delegatorIndex:  make(map[string]map[string]struct{}),
+		now:             time.Now,
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if !stored {
+		if err := store.StoreEmission(emission); err != nil {
+			return nil, err
+		}
+	}
+	s.pruneLocked()
+	return s, nil
 }
 
-func NewState() *State {
-	bc := NewTriadBlockchain()
-	return &State{
-		Users:      make(map[string]UserData),
-		Blockchain: bc,
+// replay rebuilds the state from the store: genesis validators are seeded again at the height they
+// were added and the canonical chain is re-executed, checking every block's signature and state
+// root on the way. Archived bodies are read back from the block archive.
+func (s *State) replay() error {
+	genesis, err := s.Store.GenesisValidators()
+	if err != nil {
+		return err
+	}
+	path := s.Blockchain.ForkChoice.CanonicalPath()
+	for i, parent := range path[:len(path)-1] {
+		for len(genesis) > 0 && genesis[0].Height <= parent.Index {
+			if err := s.seedValidatorLocked(genesis[0], parent.Hash); err != nil {
+				return err
+			}
+			genesis = genesis[1:]
+		}
+		block, err := s.Store.GetBlock(path[i+1].Hash)
+		if err != nil {
+			return err
+		}
+		if err := s.Blockchain.validateBlock(parent, block, true); err != nil {
+			return fmt.Errorf("failed to replay block %s: %v", block.Hash, err)
+		}
+		if err := s.applyBlockLocked(block); err != nil {
+			return fmt.Errorf("failed to replay block %s: %v", block.Hash, err)
+		}
 	}
+	for _, v := range genesis {
+		if err := s.seedValidatorLocked(v, path[len(path)-1].Hash); err != nil {
+			return err
+		}
+	}
+	// With the validator set restored, the fork choice may now prefer another branch
+	if _, changed := s.Blockchain.ForkChoice.Update(s.Blockchain); changed {
+		s.followHeadLocked(path[len(path)-1])
+	}
+	return nil
 }
 
+// NewTriadBlockchain creates a triad blockchain holding only a new genesis block.
 func NewTriadBlockchain() *TriadBlockchain {
+	genesisBlock := NewBlock(0, 0, []Transaction{}, "0", "genesis_validator")
+	bc := newTriadBlockchain(genesisBlock)
+	bc.ForkChoice.Update(bc)
+	return bc
+}
+
+// newTriadBlockchain creates a triad blockchain rooted at genesis with a fresh consensus engine.
+func newTriadBlockchain(genesis *Block) *TriadBlockchain {
 	consensus := NewConsensus()
-	genesisBlock := NewBlock(0, []Transaction{}, "0", "genesis_validator")
-	rootNode := &TriadNode{
-		Block:    genesisBlock,
-		Children: [3]*TriadNode{},
-	}
 	return &TriadBlockchain{
-		Root:      rootNode,
-		Consensus: consensus,
-		Nodes:     map[string]*TriadNode{genesisBlock.Hash: rootNode},
+		TriadTree:  NewTriadTree(genesis),
+		Consensus:  consensus,
+		ForkChoice: NewForkChoice(HeaviestSubtree{}),
+		Finality:   NewFinalityGadget(consensus, genesis),
 	}
 }
 
-// AddBlock adds a new block to the triad tree.
-func (s *State) AddBlock(transactions []Transaction, validator, parentHash string) {
+// AddBlock verifies a signed block and adds it to the triad tree.
+func (s *State) AddBlock(block *Block) error {
 	s.Blockchain.Mutex.Lock()
 	defer s.Blockchain.Mutex.Unlock()
 
-	// Verify validator
-	if validator != s.Blockchain.Consensus.SelectValidator() {
-		fmt.Printf("Invalid validator: %s\n", validator)
+	if _, exists := s.Blockchain.Nodes[block.Hash]; exists {
+		return fmt.Errorf("block %s already known", block.Hash)
+	}
+	if block.Archived {
+		return fmt.Errorf("block %s arrived without its body", block.Hash)
+	}
+
+	parentNode, exists := s.Blockchain.Nodes[block.ParentHash]
+	if !exists {
+		return fmt.Errorf("parent block not found: %s", block.ParentHash)
+	}
+	// Verify the block against its parent and the validator's registered key
+	if err := s.Blockchain.validateBlock(parentNode.Block, block, true); err != nil {
+		return err
+	}
+	s.recordProposal(block)
+
+	// The leader is checked once the block is executed on top of its parent's state
+	if hash := s.branchValidatorSetHash(parentNode); block.ValidatorSetHash != hash {
+		return fmt.Errorf("block %s validator set hash %s does not match %s", block.Hash, block.ValidatorSetHash, hash)
+	}
+
+	return s.insertBlock(block, nil)
+}
+
+// recordProposal remembers the first block each validator signed for a slot and queues
+// double-sign evidence when a different one turns up. Slots that are already settled are not
+// tracked. The caller must hold the blockchain mutex.
+func (s *State) recordProposal(block *Block) {
+	if settled := s.Blockchain.settled; settled != nil && block.Slot <= settled.Block.Slot {
+		return
+	}
+	key := proposal{validator: block.Validator, slot: block.Slot}
+	first, exists := s.proposals[key]
+	if !exists {
+		s.proposals[key] = block.Hash
+		return
+	}
+	if first == block.Hash {
 		return
 	}
+	var firstBlock *Block
+	if node, exists := s.Blockchain.Nodes[first]; exists {
+		firstBlock = node.Block
+	} else if stored, err := s.Store.GetBlock(first); err == nil {
+		firstBlock = stored
+	} else {
+		return
+	}
+	s.Evidence.Add(&Evidence{
+		Type:     EvidenceDoubleSign,
+		Offender: block.Validator,
+		Blocks:   [2]*Block{firstBlock, block},
+	})
+	slog.Warn("Double sign detected", "validator", block.Validator, "slot", block.Slot)
+}
+
+// proposal identifies a validator's block proposal for a slot.
+type proposal struct {
+	validator string
+	slot      uint64
+}
+
+// ProduceBlock builds a block in slot on top of parentHash, which must be the canonical head, from
+// the given transactions and slashing evidence, signs it with the local validator's key and adds
+// it to the triad tree. Transactions and evidence that cannot be applied are left out of the block.
+func (s *State) ProduceBlock(txs []Transaction, evidence []*Evidence, slot uint64, parentHash, privateKey string) (*Block, error) {
+	pubKey, err := PublicKeyFromPrivate(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := AddressFromPublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Blockchain.Mutex.Lock()
+	defer s.Blockchain.Mutex.Unlock()
 
-	// Find parent node
 	parentNode, exists := s.Blockchain.Nodes[parentHash]
 	if !exists {
-		fmt.Printf("Parent block not found: %s\n", parentHash)
-		return
+		return nil, fmt.Errorf("parent block not found: %s", parentHash)
+	}
+	// The block is executed against the state, which is only held at the canonical head
+	if head := s.Blockchain.ForkChoice.Head(); parentHash != head.Hash {
+		return nil, fmt.Errorf("parent %s is not the canonical head %s", parentHash, head.Hash)
+	}
+	s.Mutex.Lock()
+	e := s.newExecution(parentNode.Block.Index + 1)
+	e.releaseJailed()
+	var included []*Evidence
+	for _, ev := range evidence {
+		if err := e.applyEvidence(ev); err == nil {
+			included = append(included, ev)
+		}
+	}
+	var applied []Transaction
+	for _, tx := range txs {
+		if err := e.applyTransaction(tx); err == nil {
+			applied = append(applied, tx)
+		}
 	}
+	e.endBlock(validator)
+	stateRoot := e.stateRoot()
+	s.Mutex.Unlock()
 
-	// Check if parent can accept more children
-	if len(parentNode.Children) >= 3 {
-		fmt.Printf("Parent node has maximum children: %s\n", parentHash)
-		return
+	block := NewBlock(parentNode.Block.Index+1, slot, applied, parentHash, validator)
+	block.Timestamp = s.now().UnixNano()
+	block.Evidence = included
+	block.ValidatorSetHash = s.Blockchain.Consensus.ValidatorSet().Hash()
+	block.NextValidatorSetHash = e.nextValidatorSetHash()
+	block.StateRoot = stateRoot
+	block.seal()
+	// Never sign a block the tree would reject: a second signature for the slot is a double sign
+	if err := s.checkPlacement(parentNode, block); err != nil {
+		return nil, err
+	}
+	if err := block.SignBlock(privateKey); err != nil {
+		return nil, err
 	}
+	if err := s.insertBlock(block, e); err != nil {
+		return nil, err
+	}
+	s.recordProposal(block)
+	return block, nil
+}
 
-	newBlock := NewBlock(parentNode.Block.Index+1, transactions, parentHash, validator)
-	newNode := &TriadNode{
-		Block:    newBlock,
-		Children: [3]*TriadNode{},
+// insertBlock persists a verified block and links it into the tree. When it extends the canonical
+// head it is executed, unless e already holds its execution, and that execution is committed once
+// the block becomes the head. Blocks on side branches are kept without touching state until the
+// fork choice switches to their branch, at which point the state is reorganized.
+// The caller must hold the blockchain mutex.
+func (s *State) insertBlock(block *Block, e *execution) error {
+	// Check the parent exists and can accept more children before persisting
+	parentNode, _, err := s.Blockchain.childSlot(block)
+	if err != nil {
+		return err
 	}
-	for i := 0; i < 3; i++ {
-		if parentNode.Children[i] == nil {
-			parentNode.Children[i] = newNode
-			break
+	if err := s.checkPlacement(parentNode, block); err != nil {
+		return err
+	}
+	extendsHead := parentNode.Block.Hash == s.Blockchain.ForkChoice.Head().Hash
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if extendsHead && e == nil {
+		if e, err = s.executeBlockLocked(block); err != nil {
+			return err
 		}
 	}
-	s.Blockchain.Nodes[newBlock.Hash] = newNode
-	fmt.Printf("Block added to triad tree: index=%d, hash=%s, validator=%s\n", newBlock.Index, newBlock.Hash, validator)
+	if err := s.Store.StoreBlock(block); err != nil {
+		return err
+	}
+	if _, err := s.Blockchain.attach(block); err != nil {
+		return err
+	}
+	slog.Info("Block added to triad tree", "index", block.Index, "hash", block.Hash, "validator", block.Validator)
+
+	event, changed := s.Blockchain.ForkChoice.Update(s.Blockchain)
+	if !changed {
+		return nil
+	}
+	if extendsHead && event.NewHead == block {
+		if err := s.commitBlockLocked(block, e); err != nil {
+			return err
+		}
+	} else {
+		s.followHeadLocked(event.OldHead)
+	}
+	s.pruneLocked()
+	return nil
+}
+
+// checkPlacement checks that a block's slot follows its parent's and matches its timestamp, and
+// that the parent lies on a branch that can still become canonical.
+// The caller must hold the blockchain mutex.
+func (s *State) checkPlacement(parentNode *TriadNode, block *Block) error {
+	if block.Slot <= parentNode.Block.Slot {
+		return fmt.Errorf("block slot %d not after parent slot %d", block.Slot, parentNode.Block.Slot)
+	}
+	if err := s.checkSlot(block); err != nil {
+		return err
+	}
+	if finalized := s.Blockchain.Finality.FinalizedHead(); !s.Blockchain.isAncestor(finalized.Hash, parentNode) {
+		return fmt.Errorf("block %s does not descend from finalized block %s", block.Hash, finalized.Hash)
+	}
+	if settled := s.Blockchain.settled; settled != nil && !s.Blockchain.isAncestor(settled.Block.Hash, parentNode) {
+		return fmt.Errorf("block %s forks below pruned height %d", block.Hash, s.prunedHeight)
+	}
+	return nil
 }
 
 // ValidateBlockchain validates the triad blockchain.
 func (s *State) ValidateBlockchain() bool {
+	s.Blockchain.Mutex.Lock()
+	defer s.Blockchain.Mutex.Unlock()
 	return s.Blockchain.ValidateTree()
 }
 
-// AddUser adds a new user to the state.
-func (s *State) AddUser(address, deviceID string, data UserData) error {
-	s.Mutex.Lock()
-	defer s.Mutex.Unlock()
-
-	data.Balance = 1000
+// addUserLocked creates an account outside any block. It only seeds genesis validators; every other
+// account is created by a register transaction. The caller must hold the state mutex.
+func (s *State) addUserLocked(address, deviceID string, data UserData) error {
+	if data.PublicKey != "" {
+		derived, err := AddressFromPublicKey(data.PublicKey)
+		if err != nil {
+			return err
+		}
+		if derived != address {
+			return fmt.Errorf("public key does not match address %s", address)
+		}
+	}
+	data.Balance = 0
 	data.LastNonce = 0
 	data.Reputation = NewReputation()
 	data.Devices = []string{deviceID}
+	data.Bonded = 0
+	data.Unbonding = nil
 	s.Users[address] = data
-	s.Blockchain.Consensus.AddValidator(address, data.Balance, data.Reputation)
-	return nil
-}
-
-// UpdateUser updates a user's data.
-func (s *State) UpdateUser(address string, data UserData) {
-	s.Mutex.Lock()
-	defer s.Mutex.Unlock()
-	s.Users[address] = data
-	s.Blockchain.Consensus.AddValidator(address, data.Balance, data.Reputation)
-}
-
-// UpdateData updates a user's PoC contribution and trees planted.
-func (s *State) UpdateData(address, deviceID string, contribution PoCContribution, trees int64) {
-	s.Mutex.Lock()
-	defer s.Mutex.Unlock()
-	user, exists := s.Users[address]
-	if !exists {
-		return
+	if data.PublicKey != "" {
+		s.Blockchain.Consensus.RegisterKey(address, data.PublicKey)
 	}
-	user.PoCContribution = contribution
-	user.TreesPlanted += trees
-	s.Users[address] = user
+	return nil
 }
 
 // GetData retrieves a user's data.
@@ -137,76 +405,37 @@ func (s *State) VerifyTransaction(tx Transaction) error {
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 	user, exists := s.Users[tx.From]
-	if !exists {
-		return fmt.Errorf("user %s not found", tx.From)
-	}
-	if user.Balance < tx.Amount {
-		return fmt.Errorf("insufficient balance")
-	}
-	if tx.Nonce <= user.LastNonce {
-		return fmt.Errorf("invalid nonce")
-	}
-	// Add signature verification (simplified)
-	return nil
+	return verifyTransaction(user, exists, tx)
 }
 
-// ExecuteTransaction executes a transaction.
-func (s *State) ExecuteTransaction(from, to string, amount int64, nonce uint64) error {
-	s.Mutex.Lock()
-	defer s.Mutex.Unlock()
-	fromUser, exists := s.Users[from]
-	if !exists {
-		return fmt.Errorf("user %s not found", from)
+// verifyTransaction checks a transaction against its sender's account. Register transactions are
+// checked against the fresh account they would create.
+func verifyTransaction(user UserData, exists bool, tx Transaction) error {
+	kind, known := txKinds[tx.Type]
+	if !known {
+		return fmt.Errorf("unknown transaction type %q", tx.Type)
 	}
-	if fromUser.Balance < amount {
-		return fmt.Errorf("insufficient balance")
+	if kind.newAccount {
+		if exists {
+			return fmt.Errorf("user %s already registered", tx.From)
+		}
+		user = UserData{}
+	} else if !exists {
+		return fmt.Errorf("user %s not found", tx.From)
 	}
-	toUser, exists := s.Users[to]
-	if !exists {
-		return fmt.Errorf("recipient %s not found", to)
-	}
-	fromUser.Balance -= amount
-	fromUser.LastNonce = nonce
-	toUser.Balance += amount
-	s.Users[from] = fromUser
-	s.Users[to] = toUser
-	s.Blockchain.Consensus.AddValidator(from, fromUser.Balance, fromUser.Reputation)
-	s.Blockchain.Consensus.AddValidator(to, toUser.Balance, toUser.Reputation)
-	return nil
-}
-
-// AddDevice adds a device to the user.
-func (s *State) AddDevice(address, deviceID string) error {
-	s.Mutex.Lock()
-	defer s.Mutex.Unlock()
-	user, exists := s.Users[address]
-	if !exists {
-		return fmt.Errorf("user %s not found", address)
+	if tx.Fee < 0 {
+		return fmt.Errorf("negative fee")
 	}
-	for _, id := range user.Devices {
-		if id == deviceID {
-			return fmt.Errorf("device already added")
+	if kind.check != nil {
+		if err := kind.check(user, tx); err != nil {
+			return err
 		}
 	}
-	user.Devices = append(user.Devices, deviceID)
-	s.Users[address] = user
-	return nil
-}
-
-// RemoveDevice removes a device from the user.
-func (s *State) RemoveDevice(address, deviceID string) error {
-	s.Mutex.Lock()
-	defer s.Mutex.Unlock()
-	user, exists := s.Users[address]
-	if !exists {
-		return fmt.Errorf("user %s not found", address)
+	if tx.overdraws(user.Balance) {
+		return fmt.Errorf("insufficient balance")
 	}
-	for i, id := range user.Devices {
-		if id == deviceID {
-			user.Devices = append(user.Devices[:i], user.Devices[i+1:]...)
-			s.Users[address] = user
-			return nil
-		}
+	if tx.Nonce <= user.LastNonce {
+		return fmt.Errorf("invalid nonce")
 	}
-	return fmt.Errorf("device %s not found", deviceID)
+	return tx.VerifySignature()
 }
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func transferTestState(t *testing.T) (*State, testKey, testKey) {
+	t.Helper()
+	validator, recipient := newTestKey(t), newTestKey(t)
+	s := newTestState(t, validator)
+	for slot := uint64(1); slot <= 3; slot++ {
+		produce(t, s, validator, slot)
+	}
+	produce(t, s, validator, 4, registerTx(t, recipient))
+	return s, validator, recipient
+}
+
+func TestTransfer(t *testing.T) {
+	tests := []struct {
+		name    string
+		tx      func(validator, recipient testKey, balance int64) Transaction
+		wantErr string
+	}{
+		{name: "valid", tx: func(v, r testKey, balance int64) Transaction {
+			return Transaction{To: r.addr, Amount: 5, Fee: 1, Nonce: 1}
+		}},
+		{name: "whole balance", tx: func(v, r testKey, balance int64) Transaction {
+			return Transaction{To: r.addr, Amount: balance - 1, Fee: 1, Nonce: 1}
+		}},
+		{name: "negative amount", tx: func(v, r testKey, balance int64) Transaction {
+			return Transaction{To: r.addr, Amount: -5, Fee: 1, Nonce: 1}
+		}, wantErr: "amount must be positive"},
+		{name: "zero amount", tx: func(v, r testKey, balance int64) Transaction {
+			return Transaction{To: r.addr, Fee: 1, Nonce: 1}
+		}, wantErr: "amount must be positive"},
+		{name: "missing recipient", tx: func(v, r testKey, balance int64) Transaction {
+			return Transaction{Amount: 5, Fee: 1, Nonce: 1}
+		}, wantErr: "missing recipient"},
+		{name: "negative fee", tx: func(v, r testKey, balance int64) Transaction {
+			return Transaction{To: r.addr, Amount: 5, Fee: -1, Nonce: 1}
+		}, wantErr: "negative fee"},
+		{name: "insufficient balance", tx: func(v, r testKey, balance int64) Transaction {
+			return Transaction{To: r.addr, Amount: balance, Fee: 1, Nonce: 1}
+		}, wantErr: "insufficient balance"},
+		{name: "amount overflows with fee", tx: func(v, r testKey, balance int64) Transaction {
+			return Transaction{To: r.addr, Amount: math.MaxInt64, Fee: 1, Nonce: 1}
+		}, wantErr: "insufficient balance"},
+		{name: "stale nonce", tx: func(v, r testKey, balance int64) Transaction {
+			return Transaction{To: r.addr, Amount: 5, Fee: 1}
+		}, wantErr: "invalid nonce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, validator, recipient := transferTestState(t)
+			before, _ := s.GetData(validator.addr)
+			tx := signedTx(t, validator, tt.tx(validator, recipient, before.Balance))
+
+			err := s.VerifyTransaction(tx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			// Producing a block only includes the transaction if it is valid
+			block := produce(t, s, validator, 5, tx)
+			received, _ := s.GetData(recipient.addr)
+			if tt.wantErr != "" {
+				if len(block.Data) != 0 || received.Balance != 0 {
+					t.Fatal("invalid transfer was applied")
+				}
+				return
+			}
+			if len(block.Data) != 1 || received.Balance != tx.Amount {
+				t.Fatalf("recipient balance %d, want %d", received.Balance, tx.Amount)
+			}
+			after, _ := s.GetData(validator.addr)
+			if after.LastNonce != tx.Nonce {
+				t.Fatalf("sender nonce %d, want %d", after.LastNonce, tx.Nonce)
+			}
+			// The sender earned this block's reward and half its fee back
+			if reward := after.Balance - (before.Balance - tx.Amount - tx.Fee); reward <= 0 {
+				t.Fatalf("sender balance %d does not reflect the transfer from %d", after.Balance, before.Balance)
+			}
+		})
+	}
+}
+
+func TestAddBlockRejectsNegativeTransfer(t *testing.T) {
+	s, validator, recipient := transferTestState(t)
+	// A validator signing a block around the producer's checks must still be rejected
+	tx := signedTx(t, recipient, Transaction{To: validator.addr, Amount: -100, Nonce: 2})
+	setSlot(s, 5)
+	block := NewBlock(5, 5, []Transaction{tx}, s.Blockchain.ForkChoice.Head().Hash, validator.addr)
+	block.Timestamp = s.now().UnixNano()
+	block.ValidatorSetHash = s.Blockchain.Consensus.ValidatorSet().Hash()
+	block.seal()
+	if err := block.SignBlock(validator.priv); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddBlock(block); err == nil || !strings.Contains(err.Error(), "amount must be positive") {
+		t.Fatalf("error %v, want a rejected transfer", err)
+	}
+	if user, _ := s.GetData(recipient.addr); user.Balance != 0 {
+		t.Fatalf("recipient balance %d after a rejected block", user.Balance)
+	}
+}
+
+func TestRestartReplaysState(t *testing.T) {
+	validator, recipient := newTestKey(t), newTestKey(t)
+	s := newTestState(t, validator)
+	produce(t, s, validator, 1)
+	produce(t, s, validator, 2, registerTx(t, recipient))
+	produce(t, s, validator, 3, signedTx(t, validator, Transaction{To: recipient.addr, Amount: 3, Fee: 1, Nonce: 1}))
+
+	restarted := openTestState(t, copyStore(t, s.Store))
+	assertSameState(t, restarted, s)
+	if !restarted.ValidateBlockchain() {
+		t.Fatal("restored chain does not validate")
+	}
+	if got, want := restarted.Blockchain.Consensus.TotalStake(), s.Blockchain.Consensus.TotalStake(); got != want {
+		t.Fatalf("restored total stake %d, want %d", got, want)
+	}
+
+	// The restored node keeps producing where it left off, to the same result
+	tx := signedTx(t, recipient, Transaction{To: validator.addr, Amount: 1, Nonce: 2})
+	produce(t, s, validator, 4, tx)
+	produce(t, restarted, validator, 4, tx)
+	assertSameState(t, restarted, s)
+}
+
+func TestRestartKeepsGenesisEmission(t *testing.T) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	produce(t, s, validator, 1)
+
+	// A restart configured with another schedule still replays under the one the chain was created with
+	config := DefaultStateConfig()
+	config.SlotDuration = s.SlotDuration
+	config.Emission.InitialReward *= 2
+	restarted := openTestStateWith(t, copyStore(t, s.Store), config)
+	if restarted.Emission != s.Emission {
+		t.Fatalf("restored emission schedule %+v, want %+v", restarted.Emission, s.Emission)
+	}
+	assertSameState(t, restarted, s)
+	produce(t, s, validator, 2)
+	produce(t, restarted, validator, 2)
+	assertSameState(t, restarted, s)
+}
+
+func TestProduceBlockRepeatedSlot(t *testing.T) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	block := produce(t, s, validator, 1)
+
+	// A second block for the head's slot is rejected before it is signed
+	if _, err := s.ProduceBlock(nil, nil, 1, block.Hash, validator.priv); err == nil || !strings.Contains(err.Error(), "not after parent") {
+		t.Fatalf("error %v, want a rejected slot", err)
+	}
+	if pending := s.Evidence.Pending(); len(pending) != 0 {
+		t.Fatalf("%d pieces of evidence queued against the local validator", len(pending))
+	}
+	produce(t, s, validator, 2)
+	if user, _ := s.GetData(validator.addr); user.JailedUntil != 0 || user.Bonded != minStake {
+		t.Fatalf("validator slashed: bonded %d, jailed until %d", user.Bonded, user.JailedUntil)
+	}
+}
@@ -1,42 +1,92 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+)
+
+// Transaction history directions accepted by GetTransactions.
+const (
+	TxDirectionAll      = "all"
+	TxDirectionSent     = "sent"
+	TxDirectionReceived = "received"
+)
 
-	"github.com/syndtr/goleveldb/leveldb"
+const (
+	defaultTxPageSize = 50
+	maxTxPageSize     = 500
 )
 
-// StoreData stores user data in LevelDB.
-func StoreData(address, deviceID string, data UserData) error {
-	db, err := leveldb.OpenFile("data.db", nil)
+// TransactionPage is one page of an address's transaction history.
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"nextCursor,omitempty"` // Pass back to GetTransactions for the next page
+}
+
+// Store provides the node's typed storage (user data, transactions and blocks) over a KVStore backend.
+type Store struct {
+	kv      KVStore
+	archive *BlockArchive // Where ancient block bodies are moved; nil if bodies stay in the store
+}
+
+// NewStore creates a store over the given backend.
+func NewStore(kv KVStore) *Store {
+	return &Store{kv: kv}
+}
+
+// OpenStore opens a LevelDB-backed store at path.
+func OpenStore(path string) (*Store, error) {
+	kv, err := NewLevelDBStore(path)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %v", err)
+		return nil, err
 	}
-	defer db.Close()
+	return NewStore(kv), nil
+}
+
+// NewMemoryBackedStore creates a store held entirely in memory.
+func NewMemoryBackedStore() *Store {
+	return NewStore(NewMemoryStore())
+}
 
+// SetArchive sets the block archive that ArchiveBlock moves block bodies into. The store closes it.
+func (st *Store) SetArchive(archive *BlockArchive) {
+	st.archive = archive
+}
+
+// Close flushes and closes the backend and the block archive.
+func (st *Store) Close() error {
+	if st.archive != nil {
+		st.archive.Close()
+	}
+	return st.kv.Close()
+}
+
+// NewBatch starts a batch of writes that are applied atomically by Write.
+func (st *Store) NewBatch() Batch {
+	return st.kv.NewBatch()
+}
+
+// StoreData stores user data.
+func (st *Store) StoreData(address, deviceID string, data UserData) error {
 	dataBytes, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal data: %v", err)
 	}
 	key := fmt.Sprintf("%s:%s", address, deviceID)
-	if err := db.Put([]byte(key), dataBytes, nil); err != nil {
+	if err := st.kv.Put([]byte(key), dataBytes); err != nil {
 		return fmt.Errorf("failed to store data: %v", err)
 	}
 	return nil
 }
 
-// GetData retrieves user data from LevelDB.
-func GetData(address, deviceID string) (UserData, error) {
-	db, err := leveldb.OpenFile("data.db", nil)
-	if err != nil {
-		return UserData{}, fmt.Errorf("failed to open database: %v", err)
-	}
-	defer db.Close()
-
+// GetData retrieves user data.
+func (st *Store) GetData(address, deviceID string) (UserData, error) {
 	key := fmt.Sprintf("%s:%s", address, deviceID)
-	dataBytes, err := db.Get([]byte(key), nil)
+	dataBytes, err := st.kv.Get([]byte(key))
 	if err != nil {
 		return UserData{}, fmt.Errorf("failed to get data: %v", err)
 	}
@@ -48,49 +98,157 @@ func GetData(address, deviceID string) (UserData, error) {
 	return data, nil
 }
 
-// StoreTransaction stores a transaction in LevelDB.
-func StoreTransaction(tx Transaction) error {
-	db, err := leveldb.OpenFile("data.db", nil)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %v", err)
+// Transaction key layout:
+//
+//	txn:<hash>                          -> JSON-encoded transaction
+//	txsent:<from>:<timestamp>:<hash>    -> hash
+//	txrecv:<to>:<timestamp>:<hash>      -> hash
+//	txaddr:<address>:<timestamp>:<hash> -> hash (sent or received)
+//
+// Timestamps are zero-padded so each address's history sorts chronologically.
+func txKey(hash string) []byte {
+	return []byte("txn:" + hash)
+}
+
+func txBlockKey(hash string) []byte {
+	return []byte("txblock:" + hash)
+}
+
+func txIndexPrefix(direction, address string) (string, error) {
+	switch direction {
+	case TxDirectionAll, "":
+		return "txaddr:" + address + ":", nil
+	case TxDirectionSent:
+		return "txsent:" + address + ":", nil
+	case TxDirectionReceived:
+		return "txrecv:" + address + ":", nil
 	}
-	defer db.Close()
+	return "", fmt.Errorf("unknown transaction direction %q", direction)
+}
 
+func txIndexSuffix(tx *Transaction, hash string) string {
+	return fmt.Sprintf("%020d:%s", tx.Timestamp, hash)
+}
+
+// validTxCursor reports whether cursor has the <timestamp>:<hash> form of an index suffix.
+func validTxCursor(cursor string) bool {
+	timestamp, hash, found := strings.Cut(cursor, ":")
+	if !found || len(timestamp) != 20 || len(hash) != 2*sha256.Size {
+		return false
+	}
+	if _, err := strconv.ParseUint(timestamp, 10, 64); err != nil {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// StoreTransaction stores a transaction included in the block with blockHash and indexes it under
+// its sender and recipient.
+func (st *Store) StoreTransaction(tx Transaction, blockHash string) error {
 	dataBytes, err := json.Marshal(tx)
 	if err != nil {
 		return fmt.Errorf("failed to marshal transaction: %v", err)
 	}
-	key := fmt.Sprintf("tx:%s:%d", tx.From, tx.Nonce)
-	if err := db.Put([]byte(key), dataBytes, nil); err != nil {
+	hash := tx.Hash()
+	suffix := txIndexSuffix(&tx, hash)
+	batch := st.NewBatch()
+	batch.Put(txKey(hash), dataBytes)
+	batch.Put(txBlockKey(hash), []byte(blockHash))
+	batch.Put([]byte("txsent:"+tx.From+":"+suffix), []byte(hash))
+	batch.Put([]byte("txaddr:"+tx.From+":"+suffix), []byte(hash))
+	if tx.To != "" {
+		batch.Put([]byte("txrecv:"+tx.To+":"+suffix), []byte(hash))
+		batch.Put([]byte("txaddr:"+tx.To+":"+suffix), []byte(hash))
+	}
+	if err := batch.Write(); err != nil {
 		return fmt.Errorf("failed to store transaction: %v", err)
 	}
 	return nil
 }
 
-// GetTransactions retrieves all transactions for a user.
-func GetTransactions(address string) ([]Transaction, error) {
-	db, err := leveldb.OpenFile("data.db", nil)
+// RemoveTransaction removes a transaction and its index entries, e.g. when its block is rolled back.
+func (st *Store) RemoveTransaction(tx Transaction) error {
+	hash := tx.Hash()
+	suffix := txIndexSuffix(&tx, hash)
+	batch := st.NewBatch()
+	batch.Delete(txKey(hash))
+	batch.Delete(txBlockKey(hash))
+	batch.Delete([]byte("txsent:" + tx.From + ":" + suffix))
+	batch.Delete([]byte("txaddr:" + tx.From + ":" + suffix))
+	if tx.To != "" {
+		batch.Delete([]byte("txrecv:" + tx.To + ":" + suffix))
+		batch.Delete([]byte("txaddr:" + tx.To + ":" + suffix))
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to remove transaction: %v", err)
+	}
+	return nil
+}
+
+// GetTransaction retrieves a transaction by hash.
+func (st *Store) GetTransaction(hash string) (Transaction, error) {
+	dataBytes, err := st.kv.Get(txKey(hash))
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to get transaction %s: %v", hash, err)
+	}
+	var tx Transaction
+	if err := json.Unmarshal(dataBytes, &tx); err != nil {
+		return Transaction{}, fmt.Errorf("failed to unmarshal transaction %s: %v", hash, err)
+	}
+	return tx, nil
+}
+
+// GetTransactionBlock returns the hash of the canonical block that included a transaction.
+func (st *Store) GetTransactionBlock(hash string) (string, error) {
+	blockHash, err := st.kv.Get(txBlockKey(hash))
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
-	}
-	defer db.Close()
-
-	var transactions []Transaction
-	iter := db.NewIterator(nil, nil)
-	defer iter.Release()
-
-	for iter.Next() {
-		key := string(iter.Key())
-		if strings.HasPrefix(key, "tx:"+address+":") {
-			var tx Transaction
-			if err := json.Unmarshal(iter.Value(), &tx); err != nil {
-				continue
-			}
-			transactions = append(transactions, tx)
+		return "", fmt.Errorf("failed to get block of transaction %s: %v", hash, err)
+	}
+	return string(blockHash), nil
+}
+
+// GetTransactions retrieves one page of an address's transactions in chronological order.
+// direction selects sent, received or all transactions; cursor is the NextCursor of the previous page.
+func (st *Store) GetTransactions(address, direction string, limit int, cursor string) (TransactionPage, error) {
+	prefix, err := txIndexPrefix(direction, address)
+	if err != nil {
+		return TransactionPage{}, err
+	}
+	if limit <= 0 {
+		limit = defaultTxPageSize
+	}
+	if limit > maxTxPageSize {
+		limit = maxTxPageSize
+	}
+	var start []byte
+	if cursor != "" {
+		if !validTxCursor(cursor) {
+			return TransactionPage{}, fmt.Errorf("invalid cursor %q", cursor)
 		}
+		start = []byte(prefix + cursor)
+	}
+
+	page := TransactionPage{Transactions: []Transaction{}}
+	var txErr error
+	err = st.kv.IteratePrefix([]byte(prefix), start, func(key, value []byte) bool {
+		if len(page.Transactions) == limit {
+			page.NextCursor = string(key[len(prefix):])
+			return false
+		}
+		tx, err := st.GetTransaction(string(value))
+		if err != nil {
+			txErr = err
+			return false
+		}
+		page.Transactions = append(page.Transactions, tx)
+		return true
+	})
+	if err != nil {
+		return TransactionPage{}, fmt.Errorf("iterator error: %v", err)
 	}
-	if err := iter.Error(); err != nil {
-		return nil, fmt.Errorf("iterator error: %v", err)
+	if txErr != nil {
+		return TransactionPage{}, txErr
 	}
-	return transactions, nil
+	return page, nil
 }
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetTransactionsPagination(t *testing.T) {
+	store := NewMemoryBackedStore()
+	sender, recipient := newTestKey(t), newTestKey(t)
+	var txs []Transaction
+	for i := 1; i <= 5; i++ {
+		tx := Transaction{From: sender.addr, To: recipient.addr, Amount: int64(i), Nonce: uint64(i), Timestamp: int64(i)}
+		if err := store.StoreTransaction(tx, "block"); err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	// Another address's history must not leak into the pages
+	if err := store.StoreTransaction(Transaction{From: recipient.addr, To: newTestKey(t).addr, Amount: 1, Nonce: 1, Timestamp: 3}, "block"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		want     []int // Indexes into txs
+		wantNext bool
+	}{
+		{name: "first page", want: []int{0, 1}, wantNext: true},
+		{name: "middle page", want: []int{2, 3}, wantNext: true},
+		{name: "last page", want: []int{4}},
+	}
+	cursor := ""
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.GetTransactions(sender.addr, TxDirectionSent, 2, cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Transactions) != len(tt.want) {
+				t.Fatalf("got %d transactions, want %d", len(page.Transactions), len(tt.want))
+			}
+			for i, index := range tt.want {
+				if page.Transactions[i].Hash() != txs[index].Hash() {
+					t.Fatalf("transaction %d is not transaction %d", i, index)
+				}
+			}
+			if (page.NextCursor != "") != tt.wantNext {
+				t.Fatalf("next cursor %q, want one: %v", page.NextCursor, tt.wantNext)
+			}
+			cursor = page.NextCursor
+		})
+	}
+}
+
+func TestGetTransactionsRejectsInvalidCursor(t *testing.T) {
+	store := NewMemoryBackedStore()
+	sender := newTestKey(t)
+	tx := Transaction{From: sender.addr, Amount: 1, Nonce: 1, Timestamp: 1}
+	if err := store.StoreTransaction(tx, "block"); err != nil {
+		t.Fatal(err)
+	}
+	for _, cursor := range []string{
+		"garbage",
+		"1:" + tx.Hash(),
+		"0000000000000000000x:" + tx.Hash(),
+		"00000000000000000001:" + tx.Hash()[1:],
+		"00000000000000000001:" + strings.Repeat("z", len(tx.Hash())),
+	} {
+		if _, err := store.GetTransactions(sender.addr, TxDirectionSent, 10, cursor); err == nil || !strings.Contains(err.Error(), "invalid cursor") {
+			t.Errorf("cursor %q: error %v, want an invalid cursor", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// SignatureError reports a transaction whose signature or key-to-address binding is invalid.
+type SignatureError struct {
+	From   string
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("invalid transaction signature from %s: %s", e.From, e.Reason)
+}
+
+// AddressFromPublicKey derives an address from a hex-encoded public key (sha256 of the raw key hex, first 40 chars).
+func AddressFromPublicKey(publicKey string) (string, error) {
+	pub, err := parsePublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(encodePublicKey(pub)))
+	return fmt.Sprintf("%x", hash)[:40], nil
+}
+
+// SigningPayload returns the canonical bytes covered by the transaction signature.
+func (tx *Transaction) SigningPayload() []byte {
+	data, _ := json.Marshal(struct {
+		Type      TxType          `json:"type,omitempty"`
+		From      string          `json:"from"`
+		To        string          `json:"to"`
+		Amount    int64           `json:"amount"`
+		Fee       int64           `json:"fee,omitempty"`
+		Timestamp int64           `json:"timestamp"`
+		Nonce     uint64          `json:"nonce"`
+		PrevHash  string          `json:"prevHash"`
+		PublicKey string          `json:"publicKey"`
+		Payload   json.RawMessage `json:"payload,omitempty"`
+	}{
+		Type:      tx.Type,
+		From:      tx.From,
+		To:        tx.To,
+		Amount:    tx.Amount,
+		Fee:       tx.Fee,
+		Timestamp: tx.Timestamp,
+		Nonce:     tx.Nonce,
+		PrevHash:  tx.PrevHash,
+		PublicKey: tx.PublicKey,
+		Payload:   tx.Payload,
+	})
+	return data
+}
+
+// debit returns the amount of liquid balance a transaction spends, including its fee. It must only
+// be called once overdraws has ruled out an overflowing sum.
+func (tx *Transaction) debit() int64 {
+	if tx.spendsAmount() {
+		return tx.Amount + tx.Fee
+	}
+	return tx.Fee
+}
+
+// overdraws reports whether a transaction spends more than balance. Amount and fee are compared
+// one at a time, so a huge amount cannot wrap their sum around to a small debit.
+func (tx *Transaction) overdraws(balance int64) bool {
+	if tx.Fee > balance {
+		return true
+	}
+	return tx.spendsAmount() && tx.Amount > balance-tx.Fee
+}
+
+// spendsAmount reports whether the transaction's amount is paid out of the sender's balance.
+func (tx *Transaction) spendsAmount() bool {
+	return tx.Type == TxTransfer || tx.Type == TxStake || tx.Type == TxDelegate
+}
+
+// Size returns the encoded size of the transaction in bytes, used to price its fee.
+func (tx *Transaction) Size() int {
+	data, _ := json.Marshal(tx)
+	return len(data)
+}
+
+// Hash returns the hex-encoded sha256 of the signing payload.
+func (tx *Transaction) Hash() string {
+	hash := sha256.Sum256(tx.SigningPayload())
+	return fmt.Sprintf("%x", hash)
+}
+
+// Sign sets the sender's public key and signs the transaction with the hex-encoded private key.
+func (tx *Transaction) Sign(privateKey string) error {
+	pubKey, err := PublicKeyFromPrivate(privateKey)
+	if err != nil {
+		return err
+	}
+	tx.PublicKey = pubKey
+	hash := sha256.Sum256(tx.SigningPayload())
+	sig, err := signHash(privateKey, hash[:])
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	tx.Signature = sig
+	return nil
+}
+
+// VerifySignature checks that the public key derives the sender address and signed the payload.
+func (tx *Transaction) VerifySignature() error {
+	if tx.PublicKey == "" || tx.Signature == "" {
+		return &SignatureError{From: tx.From, Reason: "missing public key or signature"}
+	}
+	address, err := AddressFromPublicKey(tx.PublicKey)
+	if err != nil {
+		return &SignatureError{From: tx.From, Reason: err.Error()}
+	}
+	if address != tx.From {
+		return &SignatureError{From: tx.From, Reason: "public key does not match sender address"}
+	}
+	hash := sha256.Sum256(tx.SigningPayload())
+	if !verifyHash(tx.PublicKey, hash[:], tx.Signature) {
+		return &SignatureError{From: tx.From, Reason: "signature does not verify"}
+	}
+	return nil
+}
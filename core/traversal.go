@@ -0,0 +1,196 @@
+package core
+
+import "fmt"
+
+// TreeIterator yields triad tree nodes one at a time without recursion, so walking a deep tree
+// never grows the call stack. It reads the tree lazily: the tree must not change while it is used.
+type TreeIterator struct {
+	next func() *TriadNode // Returns nil once the iteration is exhausted
+}
+
+// Next returns the next node, or nil when there are no more.
+func (it *TreeIterator) Next() *TriadNode {
+	if it.next == nil {
+		return nil
+	}
+	node := it.next()
+	if node == nil {
+		it.next = nil
+	}
+	return node
+}
+
+// Blocks drains the iterator and returns the blocks of up to limit nodes; 0 means no limit.
+func (it *TreeIterator) Blocks(limit int) []*Block {
+	blocks := []*Block{}
+	for node := it.Next(); node != nil; node = it.Next() {
+		blocks = append(blocks, node.Block)
+		if len(blocks) == limit {
+			break
+		}
+	}
+	return blocks
+}
+
+// filter returns an iterator over the nodes of it for which keep returns true.
+func (it *TreeIterator) filter(keep func(node *TriadNode) bool) *TreeIterator {
+	return &TreeIterator{next: func() *TriadNode {
+		for node := it.Next(); node != nil; node = it.Next() {
+			if keep(node) {
+				return node
+			}
+		}
+		return nil
+	}}
+}
+
+// BFS iterates over the subtree at root breadth-first, visiting children in slot order. Since every
+// child is one block higher than its parent, nodes come out in order of height.
+func (t *TriadTree) BFS(root *TriadNode) *TreeIterator {
+	var queue []*TriadNode
+	if root != nil {
+		queue = append(queue, root)
+	}
+	return &TreeIterator{next: func() *TriadNode {
+		if len(queue) == 0 {
+			return nil
+		}
+		node := queue[0]
+		queue = queue[1:]
+		for _, child := range node.Children {
+			if child != nil {
+				queue = append(queue, child)
+			}
+		}
+		return node
+	}}
+}
+
+// DFS iterates over the subtree at root depth-first in pre-order, visiting children in slot order.
+func (t *TriadTree) DFS(root *TriadNode) *TreeIterator {
+	var stack []*TriadNode
+	if root != nil {
+		stack = append(stack, root)
+	}
+	return &TreeIterator{next: func() *TriadNode {
+		if len(stack) == 0 {
+			return nil
+		}
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for i := len(node.Children) - 1; i >= 0; i-- {
+			if child := node.Children[i]; child != nil {
+				stack = append(stack, child)
+			}
+		}
+		return node
+	}}
+}
+
+// lineage iterates from node up through its ancestors to the root.
+func (t *TriadTree) lineage(node *TriadNode) *TreeIterator {
+	return &TreeIterator{next: func() *TriadNode {
+		current := node
+		if current != nil && current != t.Root {
+			node = t.Nodes[current.Block.ParentHash]
+		} else {
+			node = nil
+		}
+		return current
+	}}
+}
+
+// Ancestors iterates over the ancestors of the block with hash, from its parent up to the root.
+func (t *TriadTree) Ancestors(hash string) (*TreeIterator, error) {
+	node, exists := t.Nodes[hash]
+	if !exists {
+		return nil, fmt.Errorf("block not found: %s", hash)
+	}
+	it := t.lineage(node)
+	it.Next()
+	return it, nil
+}
+
+// Descendants iterates depth-first over the descendants of the block with hash.
+func (t *TriadTree) Descendants(hash string) (*TreeIterator, error) {
+	node, exists := t.Nodes[hash]
+	if !exists {
+		return nil, fmt.Errorf("block not found: %s", hash)
+	}
+	it := t.DFS(node)
+	it.Next()
+	return it, nil
+}
+
+// Leaves iterates depth-first over the blocks without children: the tips of every branch.
+func (t *TriadTree) Leaves() *TreeIterator {
+	return t.DFS(t.Root).filter(func(node *TriadNode) bool {
+		return node.Children == [3]*TriadNode{}
+	})
+}
+
+// HeightRange iterates over the blocks with heights from min to max inclusive, in order of height.
+func (t *TriadTree) HeightRange(min, max int) *TreeIterator {
+	it := t.BFS(t.Root)
+	return &TreeIterator{next: func() *TriadNode {
+		for node := it.Next(); node != nil; node = it.Next() {
+			if node.Block.Index > max {
+				return nil
+			}
+			if node.Block.Index >= min {
+				return node
+			}
+		}
+		return nil
+	}}
+}
+
+// LowestCommonAncestor returns the deepest block that is an ancestor of, or equal to, both blocks.
+func (t *TriadTree) LowestCommonAncestor(a, b string) (*TriadNode, error) {
+	ancestor, _, _, err := t.fork(a, b)
+	return ancestor, err
+}
+
+// Path returns the blocks on the path between two blocks: up from from to their lowest common
+// ancestor, then down to to. Both ends are included.
+func (t *TriadTree) Path(from, to string) ([]*Block, error) {
+	ancestor, up, down, err := t.fork(from, to)
+	if err != nil {
+		return nil, err
+	}
+	path := append(up, ancestor.Block)
+	for i := len(down) - 1; i >= 0; i-- {
+		path = append(path, down[i])
+	}
+	return path, nil
+}
+
+// fork finds the lowest common ancestor of two blocks along with the branch from each block down
+// to it, each ordered from the block towards the ancestor and excluding the ancestor itself.
+func (t *TriadTree) fork(a, b string) (*TriadNode, []*Block, []*Block, error) {
+	nodeA, exists := t.Nodes[a]
+	if !exists {
+		return nil, nil, nil, fmt.Errorf("block not found: %s", a)
+	}
+	nodeB, exists := t.Nodes[b]
+	if !exists {
+		return nil, nil, nil, fmt.Errorf("block not found: %s", b)
+	}
+	var branchA, branchB []*Block
+	itA, itB := t.lineage(nodeA), t.lineage(nodeB)
+	nodeA, nodeB = itA.Next(), itB.Next()
+	for nodeA != nodeB {
+		if nodeA == nil || nodeB == nil {
+			return nil, nil, nil, fmt.Errorf("no common ancestor between %s and %s", a, b)
+		}
+		// Climb from the higher block until both meet
+		if nodeA.Block.Index >= nodeB.Block.Index {
+			branchA = append(branchA, nodeA.Block)
+			nodeA = itA.Next()
+		} else {
+			branchB = append(branchB, nodeB.Block)
+			nodeB = itB.Next()
+		}
+	}
+	return nodeA, branchA, branchB, nil
+}
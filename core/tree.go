@@ -1,92 +1,87 @@
 package core
 
-import (
-	"fmt"
-	"sync"
-)
+import "fmt"
 
-// TriadNode represents a node in the triad tree.
+// TriadNode represents a node in the triad tree. Child links live here rather than in the block,
+// so linking a child never changes its parent's hash. They are left out of the JSON encoding, which
+// would otherwise recurse through the whole subtree; clients rebuild the tree from ParentHash.
 type TriadNode struct {
 	Block    *Block
-	Children [3]*TriadNode // Up to 3 children
+	Children [3]*TriadNode `json:"-"` // Up to 3 children; free slots are nil
 }
 
-// TriadTree represents the triad tree structure for the blockchain.
+// TriadTree is the block tree engine: every block has at most three children and is reachable by
+// hash. It is not safe for concurrent use; TriadBlockchain guards it with its mutex.
 type TriadTree struct {
 	Root  *TriadNode
-	mutex sync.Mutex
+	Nodes map[string]*TriadNode // Map of hash to node for quick lookup
 }
 
-// NewTriadTree creates a new triad tree with a genesis block.
-func NewTriadTree() *TriadTree {
-	genesisBlock := NewBlock(0, []Transaction{}, "0", "genesis_validator")
+// NewTriadTree creates a triad tree rooted at the genesis block.
+func NewTriadTree(genesis *Block) *TriadTree {
+	root := &TriadNode{Block: genesis}
 	return &TriadTree{
-		Root: &TriadNode{
-			Block:    genesisBlock,
-			Children: [3]*TriadNode{},
-		},
+		Root:  root,
+		Nodes: map[string]*TriadNode{genesis.Hash: root},
 	}
 }
 
-// AddNode adds a new node to the triad tree.
-func (t *TriadTree) AddNode(data []Transaction, validator string, parent *TriadNode) (*TriadNode, error) {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
-
-	if len(parent.Children) >= 3 {
-		return nil, fmt.Errorf("parent node has maximum children")
-	}
-
-	newBlock := NewBlock(parent.Block.Index+1, data, parent.Block.Hash, validator)
-	newNode := &TriadNode{
-		Block:    newBlock,
-		Children: [3]*TriadNode{},
+// childSlot finds the parent of a block and the first free child slot under it.
+func (t *TriadTree) childSlot(block *Block) (*TriadNode, int, error) {
+	parentNode, exists := t.Nodes[block.ParentHash]
+	if !exists {
+		return nil, 0, fmt.Errorf("parent block not found: %s", block.ParentHash)
 	}
-
-	for i := 0; i < 3; i++ {
-		if parent.Children[i] == nil {
-			parent.Children[i] = newNode
-			break
+	for i, child := range parentNode.Children {
+		if child == nil {
+			return parentNode, i, nil
 		}
 	}
-
-	newBlock.Children = [3]string{}                                 // Initialize children hashes
-	parent.Block.Children = t.updateChildrenHashes(parent.Children) // Update parent children hashes
-	parent.Block.Hash = parent.Block.calculateHash()                // Recalculate parent hash
-
-	return newNode, nil
+	return nil, 0, fmt.Errorf("parent node has maximum children: %s", block.ParentHash)
 }
 
-// updateChildrenHashes updates the children hashes in the block.
-func (t *TriadTree) updateChildrenHashes(children [3]*TriadNode) [3]string {
-	var hashes [3]string
-	for i, child := range children {
-		if child != nil {
-			hashes[i] = child.Block.Hash
-		}
+// attach links a block under its parent in the first free child slot.
+func (t *TriadTree) attach(block *Block) (*TriadNode, error) {
+	if _, exists := t.Nodes[block.Hash]; exists {
+		return nil, fmt.Errorf("block %s already in tree", block.Hash)
+	}
+	parentNode, slot, err := t.childSlot(block)
+	if err != nil {
+		return nil, err
 	}
-	return hashes
+	node := &TriadNode{Block: block}
+	parentNode.Children[slot] = node
+	t.Nodes[block.Hash] = node
+	return node, nil
 }
 
-// ValidateTree validates the triad tree.
-func (t *TriadTree) ValidateTree() bool {
-	return t.validateNode(t.Root, "0")
+// isAncestor reports whether the block with hash is node's block or one of its ancestors.
+func (t *TriadTree) isAncestor(hash string, node *TriadNode) bool {
+	it := t.lineage(node)
+	for node := it.Next(); node != nil; node = it.Next() {
+		if node.Block.Hash == hash {
+			return true
+		}
+	}
+	return false
 }
 
-// validateNode recursively validates a node and its children.
-func (t *TriadTree) validateNode(node *TriadNode, expectedParentHash string) bool {
-	if node.Block.ParentHash != expectedParentHash {
-		return false
-	}
-	if node.Block.Hash != node.Block.calculateHash() {
-		return false
+// detach removes a block and all its descendants from the tree and returns the removed blocks.
+func (t *TriadTree) detach(hash string) []*Block {
+	node, exists := t.Nodes[hash]
+	if !exists || node == t.Root {
+		return nil
 	}
-	for _, child := range node.Children {
-		if child != nil {
-			if !t.validateNode(child, node.Block.Hash) {
-				return false
+	if parentNode, exists := t.Nodes[node.Block.ParentHash]; exists {
+		for i, child := range parentNode.Children {
+			if child == node {
+				parentNode.Children[i] = nil
 			}
 		}
 	}
-	return true
+	removed := t.DFS(node).Blocks(0)
+	for _, block := range removed {
+		delete(t.Nodes, block.Hash)
+	}
+	return removed
 }
@@ -0,0 +1,315 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttach(t *testing.T) {
+	tests := []struct {
+		name     string
+		children int    // Children attached under genesis before the checked one
+		wantSlot int    // Slot the checked block lands in
+		wantErr  string // Expected error, if any
+	}{
+		{name: "first child", children: 0, wantSlot: 0},
+		{name: "second child", children: 1, wantSlot: 1},
+		{name: "third child", children: 2, wantSlot: 2},
+		{name: "fourth child", children: 3, wantErr: "maximum children"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genesis := NewBlock(0, 0, []Transaction{}, "0", "genesis_validator")
+			genesisHash := genesis.Hash
+			tree := NewTriadTree(genesis)
+			for i := 0; i < tt.children; i++ {
+				if _, err := tree.attach(NewBlock(1, uint64(i+1), nil, genesis.Hash, "v")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			block := NewBlock(1, 10, nil, genesis.Hash, "v")
+			parent, slot, err := tree.childSlot(block)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("childSlot error %v, want %q", err, tt.wantErr)
+				}
+				if _, err := tree.attach(block); err == nil {
+					t.Fatal("attach succeeded on a full parent")
+				}
+				if _, exists := tree.Nodes[block.Hash]; exists {
+					t.Fatal("rejected block was added to the tree")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parent != tree.Root || slot != tt.wantSlot {
+				t.Fatalf("childSlot got slot %d, want %d under the root", slot, tt.wantSlot)
+			}
+			node, err := tree.attach(block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tree.Root.Children[tt.wantSlot] != node || tree.Nodes[block.Hash] != node {
+				t.Fatal("attached node is not linked into the tree")
+			}
+			// Linking children must never change the parent's hash
+			if genesis.Hash != genesisHash || genesis.calculateHash() != genesisHash {
+				t.Fatal("attaching a child changed the parent hash")
+			}
+			if _, err := tree.attach(block); err == nil {
+				t.Fatal("attaching the same block twice succeeded")
+			}
+		})
+	}
+}
+
+func TestAttachUnknownParent(t *testing.T) {
+	tree := NewTriadTree(NewBlock(0, 0, []Transaction{}, "0", "genesis_validator"))
+	if _, err := tree.attach(NewBlock(1, 1, nil, "missing", "v")); err == nil {
+		t.Fatal("attached a block without a parent in the tree")
+	}
+}
+
+func TestDetach(t *testing.T) {
+	// genesis -> a -> (b -> d, c)
+	genesis := NewBlock(0, 0, []Transaction{}, "0", "genesis_validator")
+	tree := NewTriadTree(genesis)
+	a := NewBlock(1, 1, nil, genesis.Hash, "v")
+	b := NewBlock(2, 2, nil, a.Hash, "v")
+	c := NewBlock(2, 3, nil, a.Hash, "v")
+	d := NewBlock(3, 4, nil, b.Hash, "v")
+	for _, block := range []*Block{a, b, c, d} {
+		if _, err := tree.attach(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		hash        string
+		wantRemoved []*Block
+		wantLeft    int // Nodes left in the tree
+	}{
+		{name: "unknown block", hash: "missing", wantLeft: 5},
+		{name: "root", hash: genesis.Hash, wantLeft: 5},
+		{name: "subtree", hash: b.Hash, wantRemoved: []*Block{b, d}, wantLeft: 3},
+		{name: "leaf", hash: c.Hash, wantRemoved: []*Block{c}, wantLeft: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removed := tree.detach(tt.hash)
+			if len(removed) != len(tt.wantRemoved) {
+				t.Fatalf("removed %d blocks, want %d", len(removed), len(tt.wantRemoved))
+			}
+			for i, block := range removed {
+				if block != tt.wantRemoved[i] {
+					t.Fatalf("removed block %d is %s, want %s", i, block.Hash, tt.wantRemoved[i].Hash)
+				}
+				if _, exists := tree.Nodes[block.Hash]; exists {
+					t.Fatalf("removed block %s still indexed", block.Hash)
+				}
+			}
+			if len(tree.Nodes) != tt.wantLeft {
+				t.Fatalf("%d nodes left, want %d", len(tree.Nodes), tt.wantLeft)
+			}
+		})
+	}
+
+	// Both of a's slots are free again
+	node := tree.Nodes[a.Hash]
+	if node.Children != [3]*TriadNode{} {
+		t.Fatal("detached children are still linked")
+	}
+	if _, slot, err := tree.childSlot(NewBlock(2, 5, nil, a.Hash, "v")); err != nil || slot != 0 {
+		t.Fatalf("childSlot after detach got slot %d, error %v", slot, err)
+	}
+}
+
+func TestValidateBlock(t *testing.T) {
+	validator, other := newTestKey(t), newTestKey(t)
+	bc := NewTriadBlockchain()
+	bc.Consensus.RegisterKey(validator.addr, validator.pub)
+	parent := bc.Root.Block
+
+	newChild := func() *Block {
+		tx := signedTx(t, validator, Transaction{To: other.addr, Amount: 1, Nonce: 1})
+		block := NewBlock(1, 1, []Transaction{tx}, parent.Hash, validator.addr)
+		if err := block.SignBlock(validator.priv); err != nil {
+			t.Fatal(err)
+		}
+		return block
+	}
+	tests := []struct {
+		name            string
+		modify          func(b *Block)
+		verifySignature bool
+		wantErr         string
+	}{
+		{name: "valid", modify: func(b *Block) {}, verifySignature: true},
+		{name: "wrong parent", modify: func(b *Block) {
+			b.ParentHash = "other"
+			b.seal()
+		}, verifySignature: true, wantErr: "parent hash"},
+		{name: "wrong index", modify: func(b *Block) {
+			b.Index = 2
+			b.seal()
+		}, verifySignature: true, wantErr: "invalid block index"},
+		{name: "tampered header", modify: func(b *Block) { b.Validator = other.addr }, verifySignature: true, wantErr: "hash does not match"},
+		{name: "tampered body", modify: func(b *Block) { b.Data[0].Amount = 100 }, verifySignature: true, wantErr: "body does not match"},
+		{name: "dropped transaction", modify: func(b *Block) { b.Data = nil }, verifySignature: true, wantErr: "body does not match"},
+		{name: "archived body skipped", modify: func(b *Block) {
+			b.Data = nil
+			b.Archived = true
+		}, verifySignature: true},
+		{name: "foreign signature", modify: func(b *Block) {
+			if err := b.SignBlock(other.priv); err != nil {
+				t.Fatal(err)
+			}
+		}, verifySignature: true, wantErr: "invalid signature"},
+		{name: "missing signature", modify: func(b *Block) { b.Signature = "" }, verifySignature: true, wantErr: "invalid signature"},
+		{name: "signature not checked", modify: func(b *Block) { b.Signature = "" }},
+		{name: "unregistered validator", modify: func(b *Block) {
+			b.Validator = other.addr
+			b.seal()
+			if err := b.SignBlock(other.priv); err != nil {
+				t.Fatal(err)
+			}
+		}, verifySignature: true, wantErr: "no registered key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := newChild()
+			tt.modify(block)
+			err := bc.validateBlock(parent, block, tt.verifySignature)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// branchedTree builds genesis -> a -> (b -> d -> e, c) plus genesis -> f and returns the tree with
+// its blocks by name.
+func branchedTree(t *testing.T) (*TriadTree, map[string]*Block) {
+	t.Helper()
+	blocks := map[string]*Block{"genesis": NewBlock(0, 0, []Transaction{}, "0", "genesis_validator")}
+	tree := NewTriadTree(blocks["genesis"])
+	for i, link := range []struct{ name, parent string }{
+		{"a", "genesis"}, {"b", "a"}, {"c", "a"}, {"d", "b"}, {"e", "d"}, {"f", "genesis"},
+	} {
+		parent := blocks[link.parent]
+		blocks[link.name] = NewBlock(parent.Index+1, uint64(i+1), nil, parent.Hash, "v")
+		if _, err := tree.attach(blocks[link.name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tree, blocks
+}
+
+// assertBlocks fails unless got holds the named blocks in order.
+func assertBlocks(t *testing.T, got []*Block, blocks map[string]*Block, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d blocks, want %v", len(got), want)
+	}
+	for i, name := range want {
+		if got[i] != blocks[name] {
+			t.Fatalf("block %d is %s, want %s", i, got[i].Hash, name)
+		}
+	}
+}
+
+func TestLowestCommonAncestor(t *testing.T) {
+	tree, blocks := branchedTree(t)
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{a: "d", b: "c", want: "a"},
+		{a: "c", b: "e", want: "a"},
+		{a: "e", b: "f", want: "genesis"},
+		{a: "b", b: "e", want: "b"},
+		{a: "c", b: "c", want: "c"},
+		{a: "genesis", b: "d", want: "genesis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" and "+tt.b, func(t *testing.T) {
+			node, err := tree.LowestCommonAncestor(blocks[tt.a].Hash, blocks[tt.b].Hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if node.Block != blocks[tt.want] {
+				t.Fatalf("lowest common ancestor %s, want %s", node.Block.Hash, tt.want)
+			}
+		})
+	}
+	if _, err := tree.LowestCommonAncestor(blocks["a"].Hash, "missing"); err == nil {
+		t.Fatal("found a common ancestor with an unknown block")
+	}
+}
+
+func TestPath(t *testing.T) {
+	tree, blocks := branchedTree(t)
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{from: "d", to: "c", want: []string{"d", "b", "a", "c"}},
+		{from: "c", to: "d", want: []string{"c", "a", "b", "d"}},
+		{from: "e", to: "f", want: []string{"e", "d", "b", "a", "genesis", "f"}},
+		{from: "a", to: "e", want: []string{"a", "b", "d", "e"}},
+		{from: "e", to: "a", want: []string{"e", "d", "b", "a"}},
+		{from: "b", to: "b", want: []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			path, err := tree.Path(blocks[tt.from].Hash, blocks[tt.to].Hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertBlocks(t, path, blocks, tt.want)
+		})
+	}
+	if _, err := tree.Path("missing", blocks["a"].Hash); err == nil {
+		t.Fatal("found a path from an unknown block")
+	}
+}
+
+func TestHeightRange(t *testing.T) {
+	tree, blocks := branchedTree(t)
+	tests := []struct {
+		name     string
+		min, max int
+		want     []string
+	}{
+		{name: "genesis only", min: 0, max: 0, want: []string{"genesis"}},
+		{name: "both branches", min: 1, max: 2, want: []string{"a", "f", "b", "c"}},
+		{name: "single height", min: 3, max: 3, want: []string{"d"}},
+		{name: "past the tips", min: 4, max: 10, want: []string{"e"}},
+		{name: "above the tree", min: 5, max: 10},
+		{name: "empty range", min: 2, max: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertBlocks(t, tree.HeightRange(tt.min, tt.max).Blocks(0), blocks, tt.want)
+		})
+	}
+}
+
+func TestLeaves(t *testing.T) {
+	tree, blocks := branchedTree(t)
+	assertBlocks(t, tree.Leaves().Blocks(0), blocks, []string{"e", "c", "f"})
+
+	// Detaching a branch turns its parent back into a leaf
+	tree.detach(blocks["d"].Hash)
+	assertBlocks(t, tree.Leaves().Blocks(0), blocks, []string{"b", "c", "f"})
+}
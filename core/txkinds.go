@@ -0,0 +1,214 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TxType identifies what a transaction does. Transfers, staking and delegation use To and Amount;
+// the other kinds carry a typed payload. Untyped transactions are plain transfers.
+type TxType string
+
+const (
+	TxTransfer     TxType = ""              // Moves Amount from the sender to To
+	TxRegister     TxType = "register"      // Creates the sender's empty account; RegisterPayload
+	TxContribute   TxType = "contribute"    // Reports the sender's PoC contribution; ContributePayload
+	TxAddDevice    TxType = "add_device"    // Adds a device to the sender's account; DevicePayload
+	TxRemoveDevice TxType = "remove_device" // Removes a device from the sender's account; DevicePayload
+	TxEcoAction    TxType = "eco_action"    // Records trees planted by the sender; EcoActionPayload
+)
+
+// RegisterPayload is the payload of a register transaction.
+type RegisterPayload struct {
+	DeviceID string `json:"deviceID"`
+}
+
+// ContributePayload is the payload of a contribute transaction.
+type ContributePayload struct {
+	DeviceID     string          `json:"deviceID"`
+	Contribution PoCContribution `json:"contribution"`
+}
+
+// DevicePayload is the payload of add_device and remove_device transactions.
+type DevicePayload struct {
+	DeviceID string `json:"deviceID"`
+}
+
+// EcoActionPayload is the payload of an eco_action transaction.
+type EcoActionPayload struct {
+	Trees int64 `json:"trees"`
+}
+
+// txKind describes how a transaction type is checked against its sender's account and applied.
+type txKind struct {
+	newAccount bool                                                    // The transaction creates the sender's account
+	check      func(user UserData, tx Transaction) error               // Optional checks beyond balance, nonce and signature
+	apply      func(e *execution, user UserData, tx Transaction) error // Applies the transaction after its fee is charged
+}
+
+// txKinds is the state-transition dispatcher: every transaction type and how it is handled.
+var txKinds = map[TxType]txKind{
+	TxTransfer:      {check: checkTransfer, apply: (*execution).applyTransfer},
+	TxStake:         {check: checkStakeAmount, apply: (*execution).applyStaking},
+	TxUnstake:       {check: checkStakeAmount, apply: (*execution).applyStaking},
+	TxWithdraw:      {apply: (*execution).applyStaking},
+	TxDelegate:      {check: checkStakeAmount, apply: (*execution).applyDelegation},
+	TxUndelegate:    {check: checkStakeAmount, apply: (*execution).applyDelegation},
+	TxSetCommission: {check: checkCommission, apply: (*execution).applyDelegation},
+	TxRegister:      {newAccount: true, check: checkPayload, apply: (*execution).applyRegister},
+	TxContribute:    {check: checkPayload, apply: (*execution).applyContribute},
+	TxAddDevice:     {check: checkPayload, apply: (*execution).applyDevice},
+	TxRemoveDevice:  {check: checkPayload, apply: (*execution).applyDevice},
+	TxEcoAction:     {check: checkPayload, apply: (*execution).applyEcoAction},
+}
+
+// DecodePayload unmarshals the transaction payload into v.
+func (tx *Transaction) DecodePayload(v interface{}) error {
+	if len(tx.Payload) == 0 {
+		return fmt.Errorf("missing %s payload", tx.Type)
+	}
+	if err := json.Unmarshal(tx.Payload, v); err != nil {
+		return fmt.Errorf("invalid %s payload: %v", tx.Type, err)
+	}
+	return nil
+}
+
+// checkTransfer checks the amount and recipient of a transfer. A negative amount would turn the
+// debit into a credit.
+func checkTransfer(user UserData, tx Transaction) error {
+	if tx.Amount <= 0 {
+		return fmt.Errorf("transfer amount must be positive")
+	}
+	if tx.To == "" {
+		return fmt.Errorf("missing recipient")
+	}
+	return nil
+}
+
+// checkStakeAmount checks the amount of a staking or delegation transaction.
+func checkStakeAmount(user UserData, tx Transaction) error {
+	if tx.Amount <= 0 {
+		return fmt.Errorf("stake amount must be positive")
+	}
+	if tx.Type == TxUnstake && user.Bonded < tx.Amount {
+		return fmt.Errorf("insufficient bonded stake")
+	}
+	if tx.Type == TxUndelegate && user.Delegations[tx.To] < tx.Amount {
+		return fmt.Errorf("insufficient stake delegated to %s", tx.To)
+	}
+	return nil
+}
+
+// checkCommission checks the commission percentage of a set_commission transaction.
+func checkCommission(user UserData, tx Transaction) error {
+	if tx.Amount < 0 || tx.Amount > 100 {
+		return fmt.Errorf("commission must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+// checkPayload checks that a payload-carrying transaction moves no funds and has a valid payload.
+func checkPayload(user UserData, tx Transaction) error {
+	if tx.Amount != 0 || tx.To != "" {
+		return fmt.Errorf("%s transactions carry no amount or recipient", tx.Type)
+	}
+	var err error
+	switch tx.Type {
+	case TxRegister:
+		var payload RegisterPayload
+		err = tx.DecodePayload(&payload)
+	case TxContribute:
+		var payload ContributePayload
+		err = tx.DecodePayload(&payload)
+	case TxAddDevice, TxRemoveDevice:
+		var payload DevicePayload
+		if err = tx.DecodePayload(&payload); err == nil && payload.DeviceID == "" {
+			err = fmt.Errorf("missing device ID")
+		}
+	case TxEcoAction:
+		var payload EcoActionPayload
+		if err = tx.DecodePayload(&payload); err == nil && payload.Trees <= 0 {
+			err = fmt.Errorf("trees planted must be positive")
+		}
+	}
+	return err
+}
+
+// applyRegister fills in the account created for the sender of a register transaction.
+func (e *execution) applyRegister(user UserData, tx Transaction) error {
+	var payload RegisterPayload
+	if err := tx.DecodePayload(&payload); err != nil {
+		return err
+	}
+	user.PublicKey = tx.PublicKey
+	user.Reputation = NewReputation()
+	user.Devices = []string{payload.DeviceID}
+	e.updates[tx.From] = user
+	return nil
+}
+
+// applyContribute records a PoC contribution and updates the sender's reputation. The reported
+// uptime is not verified, so an honest report never raises the score that weighs validators in
+// leader selection and fork choice. A contribution flagged by DetectCheat is a signed invalid claim,
+// so the sender's score drops and it is slashed as well.
+func (e *execution) applyContribute(user UserData, tx Transaction) error {
+	var payload ContributePayload
+	if err := tx.DecodePayload(&payload); err != nil {
+		return err
+	}
+	isHonest := !DetectCheat(payload.Contribution.Computations)
+	user = user.clone()
+	rep := user.Reputation
+	if rep == nil {
+		rep = NewReputation()
+	}
+	score := rep.Score
+	user.Reputation = UpdateReputation(rep, payload.Contribution.Uptime, isHonest)
+	if isHonest {
+		user.Reputation.Score = score
+	}
+	user.PoCContribution = payload.Contribution
+	e.updates[tx.From] = user
+	if !isHonest {
+		return e.slash(tx.From)
+	}
+	return nil
+}
+
+// applyDevice adds or removes a device of the sender.
+func (e *execution) applyDevice(user UserData, tx Transaction) error {
+	var payload DevicePayload
+	if err := tx.DecodePayload(&payload); err != nil {
+		return err
+	}
+	index := -1
+	for i, id := range user.Devices {
+		if id == payload.DeviceID {
+			index = i
+		}
+	}
+	user = user.clone()
+	switch {
+	case tx.Type == TxAddDevice && index >= 0:
+		return fmt.Errorf("device already added")
+	case tx.Type == TxAddDevice:
+		user.Devices = append(user.Devices, payload.DeviceID)
+	case index < 0:
+		return fmt.Errorf("device %s not found", payload.DeviceID)
+	default:
+		user.Devices = append(user.Devices[:index], user.Devices[index+1:]...)
+	}
+	e.updates[tx.From] = user
+	return nil
+}
+
+// applyEcoAction records trees planted by the sender.
+func (e *execution) applyEcoAction(user UserData, tx Transaction) error {
+	var payload EcoActionPayload
+	if err := tx.DecodePayload(&payload); err != nil {
+		return err
+	}
+	user.TreesPlanted += payload.Trees
+	e.updates[tx.From] = user
+	return nil
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContributeKeepsConsensusWeight(t *testing.T) {
+	validator := newTestKey(t)
+	s := newTestState(t, validator)
+	weight := s.Blockchain.Consensus.Weight(validator.addr)
+
+	payload, err := json.Marshal(ContributePayload{DeviceID: "device", Contribution: PoCContribution{Computations: 10, Uptime: 1 << 40}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []Transaction
+	for nonce := uint64(1); nonce <= 5; nonce++ {
+		txs = append(txs, signedTx(t, validator, Transaction{Type: TxContribute, Nonce: nonce, Payload: payload}))
+	}
+	block := produce(t, s, validator, 1, txs...)
+	if len(block.Data) != len(txs) {
+		t.Fatalf("block includes %d of %d contributions", len(block.Data), len(txs))
+	}
+
+	user, _ := s.GetData(validator.addr)
+	if user.Reputation.Score != NewReputation().Score || user.Reputation.Contributions != 5<<40 {
+		t.Fatalf("reputation %+v after self-reported uptime", *user.Reputation)
+	}
+	if got := s.Blockchain.Consensus.Weight(validator.addr); got != weight {
+		t.Fatalf("weight %d, want %d", got, weight)
+	}
+}
+
+func TestDelegationRewardedInItsOwnBlock(t *testing.T) {
+	validator, delegator := newTestKey(t), newTestKey(t)
+	config := DefaultStateConfig()
+	config.SlotDuration = time.Second
+	config.Emission = EmissionSchedule{InitialReward: 1000}
+	s := openTestStateWith(t, NewMemoryBackedStore(), config)
+	if err := s.AddGenesisValidator(validator.addr, "device", validator.pub, minStake); err != nil {
+		t.Fatal(err)
+	}
+	produce(t, s, validator, 1, registerTx(t, delegator))
+	other := openTestState(t, copyStore(t, s.Store))
+
+	// The delegation made in the block already shares that block's reward
+	produce(t, s, validator, 2,
+		signedTx(t, validator, Transaction{To: delegator.addr, Amount: 1000, Nonce: 1}),
+		signedTx(t, delegator, Transaction{Type: TxDelegate, To: validator.addr, Amount: minStake, Nonce: 2}))
+	user, _ := s.GetData(delegator.addr)
+	if want := int64(1000 - minStake + 500); user.Balance != want {
+		t.Fatalf("delegator balance %d, want %d", user.Balance, want)
+	}
+	if _, indexed := s.delegatorIndex[validator.addr][delegator.addr]; !indexed {
+		t.Fatal("delegation missing from the delegator index")
+	}
+
+	// Reorging the delegation away drops it from the index again
+	importBlocks(t, s, growBranch(t, other, validator, 2, 2, newTestKey(t)))
+	if got := s.Blockchain.ForkChoice.Head().Hash; got != other.Blockchain.ForkChoice.Head().Hash {
+		t.Fatalf("head %s, want the competing branch", got)
+	}
+	if len(s.delegatorIndex) != 0 {
+		t.Fatalf("delegator index %v after the delegation was reorged away", s.delegatorIndex)
+	}
+}
+
+// stakingTestState returns a state holding an account for key with the given balance and bonded stake.
+func stakingTestState(t *testing.T, key testKey, balance, bonded int64) *State {
+	t.Helper()
+	s := openTestState(t, NewMemoryBackedStore())
+	s.Users[key.addr] = UserData{PublicKey: key.pub, Balance: balance, Bonded: bonded, Reputation: NewReputation()}
+	return s
+}
+
+func TestUnbondingPeriod(t *testing.T) {
+	const unstakeHeight = 10
+	tests := []struct {
+		name        string
+		height      int // Height the withdrawal is executed at
+		wantErr     string
+		wantBalance int64
+	}{
+		{name: "right after unstaking", height: unstakeHeight, wantErr: "no unbonded stake"},
+		{name: "one block before maturity", height: unstakeHeight + unbondingBlocks - 1, wantErr: "no unbonded stake"},
+		{name: "at maturity", height: unstakeHeight + unbondingBlocks, wantBalance: 100},
+		{name: "long after maturity", height: unstakeHeight + 10*unbondingBlocks, wantBalance: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			staker := newTestKey(t)
+			s := stakingTestState(t, staker, 0, 2*minStake)
+			e := s.newExecution(unstakeHeight)
+			if err := e.applyTransaction(signedTx(t, staker, Transaction{Type: TxUnstake, Amount: 100, Nonce: 1})); err != nil {
+				t.Fatal(err)
+			}
+			user := e.updates[staker.addr]
+			if user.Bonded != 2*minStake-100 || len(user.Unbonding) != 1 || user.Unbonding[0].ReleaseHeight != unstakeHeight+unbondingBlocks {
+				t.Fatalf("after unstaking: bonded %d, unbonding %+v", user.Bonded, user.Unbonding)
+			}
+			s.Users[staker.addr] = user
+
+			e = s.newExecution(tt.height)
+			err := e.applyTransaction(signedTx(t, staker, Transaction{Type: TxWithdraw, Nonce: 2}))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user := e.updates[staker.addr]; user.Balance != tt.wantBalance || len(user.Unbonding) != 0 {
+				t.Fatalf("balance %d, unbonding %+v after withdrawing", user.Balance, user.Unbonding)
+			}
+		})
+	}
+}
+
+func TestSlashCutsUnbondingStake(t *testing.T) {
+	offender := newTestKey(t)
+	s := stakingTestState(t, offender, 1000, 200)
+	user := s.Users[offender.addr]
+	user.Unbonding = []Unbonding{
+		{Amount: 100, ReleaseHeight: 60},
+		{Amount: 50, ReleaseHeight: 60, Validator: "other"},
+	}
+	s.Users[offender.addr] = user
+
+	e := s.newExecution(20)
+	if err := e.slash(offender.addr); err != nil {
+		t.Fatal(err)
+	}
+	slashed := e.updates[offender.addr]
+	if slashed.Bonded != 180 || slashed.Balance != 1000 {
+		t.Fatalf("bonded %d, balance %d after slashing", slashed.Bonded, slashed.Balance)
+	}
+	// Own unbonding stake is still slashable; stake unbonding from another validator is not
+	if slashed.Unbonding[0].Amount != 90 || slashed.Unbonding[1].Amount != 50 {
+		t.Fatalf("unbonding %+v after slashing", slashed.Unbonding)
+	}
+	if s.Users[offender.addr].Unbonding[0].Amount != 100 {
+		t.Fatal("slashing changed the committed account")
+	}
+}
+
+// commitAccounts writes accounts to the state through an execution, as a block would.
+func commitAccounts(t *testing.T, s *State, accounts map[string]UserData) {
+	t.Helper()
+	e := s.newExecution(0)
+	for address, user := range accounts {
+		e.updates[address] = user
+	}
+	e.stateRoot()
+	s.commitLocked(e)
+}
+
+func TestRewardSplitsCommission(t *testing.T) {
+	tests := []struct {
+		name                     string
+		commission, amount       int64
+		wantValidator, wantSmall int64
+		wantLarge                int64
+	}{
+		{name: "no commission", amount: 1000, wantValidator: 250, wantSmall: 250, wantLarge: 500},
+		{name: "ten percent", commission: 10, amount: 1000, wantValidator: 325, wantSmall: 225, wantLarge: 450},
+		{name: "whole reward", commission: 100, amount: 1000, wantValidator: 1000},
+		{name: "rounding to the validator", amount: 7, wantValidator: 3, wantSmall: 1, wantLarge: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openTestState(t, NewMemoryBackedStore())
+			commitAccounts(t, s, map[string]UserData{
+				"validator": {Bonded: minStake, Delegated: 3 * minStake, Commission: tt.commission},
+				"small":     {Delegations: map[string]int64{"validator": minStake}},
+				"large":     {Delegations: map[string]int64{"validator": 2 * minStake}},
+			})
+			e := s.newExecution(1)
+			e.reward("validator", tt.amount)
+			for address, want := range map[string]int64{"validator": tt.wantValidator, "small": tt.wantSmall, "large": tt.wantLarge} {
+				if user, _ := e.account(address); user.Balance != want {
+					t.Errorf("%s paid %d, want %d", address, user.Balance, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSlashCutsUndelegatedStake(t *testing.T) {
+	s := openTestState(t, NewMemoryBackedStore())
+	commitAccounts(t, s, map[string]UserData{
+		"validator": {Bonded: 2 * minStake, Delegated: minStake},
+		"delegator": {
+			Delegations: map[string]int64{"validator": minStake},
+			Unbonding:   []Unbonding{{Amount: 50, ReleaseHeight: 60, Validator: "validator"}},
+		},
+		// Fully undelegated, but its stake is still unbonding and slashable
+		"leaving": {Unbonding: []Unbonding{{Amount: 100, ReleaseHeight: 60, Validator: "validator"}}},
+	})
+
+	e := s.newExecution(20)
+	if err := e.slash("validator"); err != nil {
+		t.Fatal(err)
+	}
+	delegator, _ := e.account("delegator")
+	if delegator.Delegations["validator"] != 90 || delegator.Unbonding[0].Amount != 45 {
+		t.Fatalf("delegator delegates %d, unbonds %+v after the slash", delegator.Delegations["validator"], delegator.Unbonding)
+	}
+	leaving, _ := e.account("leaving")
+	if leaving.Unbonding[0].Amount != 90 || len(leaving.Delegations) != 0 {
+		t.Fatalf("undelegated account unbonds %+v, delegates %v after the slash", leaving.Unbonding, leaving.Delegations)
+	}
+	if validator, _ := e.account("validator"); validator.Delegated != 90 {
+		t.Fatalf("validator holds %d delegated stake, want 90", validator.Delegated)
+	}
+}
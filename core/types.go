@@ -1,25 +1,64 @@
 package core
 
-import "sync"
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
 
 // UserData represents user data in the blockchain.
 type UserData struct {
-	Balance         int64
+	PublicKey       string
+	Balance         int64            // Liquid balance
+	Bonded          int64            // Stake bonded to validation
+	Unbonding       []Unbonding      // Unstaked amounts waiting out the unbonding delay
+	Delegations     map[string]int64 // Validator -> stake this account delegates to it
+	Delegated       int64            // Stake delegated to this account by others
+	Commission      int64            // Percent of block rewards kept before sharing with delegators
 	LastNonce       uint64
 	Reputation      *Reputation
 	Devices         []string
 	PoCContribution PoCContribution
 	TreesPlanted    int64
+	JailedUntil     int // Height until which the account is excluded from consensus after slashing
+}
+
+// clone returns a copy of the account sharing no slices, maps or pointers with it. Executions
+// clone an account before changing any of those in place, since the original may still be held by
+// the state or an undo journal. Empty and nil fields are kept apart, as they encode differently.
+func (u UserData) clone() UserData {
+	if u.Unbonding != nil {
+		u.Unbonding = append(make([]Unbonding, 0, len(u.Unbonding)), u.Unbonding...)
+	}
+	if u.Delegations != nil {
+		delegations := make(map[string]int64, len(u.Delegations))
+		for validator, amount := range u.Delegations {
+			delegations[validator] = amount
+		}
+		u.Delegations = delegations
+	}
+	if u.Reputation != nil {
+		rep := *u.Reputation
+		u.Reputation = &rep
+	}
+	if u.Devices != nil {
+		u.Devices = append(make([]string, 0, len(u.Devices)), u.Devices...)
+	}
+	return u
 }
 
 // Transaction represents a blockchain transaction.
 type Transaction struct {
+	Type      TxType
 	From      string
 	To        string
 	Amount    int64
+	Fee       int64 // Paid to the block validator, partly burned
 	Timestamp int64
 	Nonce     uint64
 	PrevHash  string
+	PublicKey string
+	Payload   json.RawMessage `json:",omitempty"` // Kind-specific data, e.g. RegisterPayload
 	Signature string
 }
 
@@ -34,28 +73,84 @@ type PoCContribution struct {
 
 // TriadBlockchain represents the blockchain as a triad tree.
 type TriadBlockchain struct {
-	Root      *TriadNode
-	Mutex     sync.Mutex
-	Consensus *Consensus
-	Nodes     map[string]*TriadNode // Map of hash to node for quick lookup
+	*TriadTree
+	Mutex      sync.Mutex
+	Consensus  *Consensus
+	ForkChoice *ForkChoice
+	Finality   *FinalityGadget
+
+	settled *TriadNode // Canonical block at the pruned height; nil until anything is pruned
 }
 
-// ValidateTree validates the triad blockchain.
-func (bc *TriadBlockchain) ValidateTree() bool {
-	return bc.Root != nil && bc.validateNode(bc.Root, "0")
+// FinalizedNode returns the tree node of the finalized head; fork choice never looks below it.
+func (bc *TriadBlockchain) FinalizedNode() *TriadNode {
+	if bc.Finality != nil {
+		if node, exists := bc.Nodes[bc.Finality.FinalizedHead().Hash]; exists {
+			return node
+		}
+	}
+	return bc.Root
 }
 
-// validateNode recursively validates a node and its children.
-func (bc *TriadBlockchain) validateNode(node *TriadNode, expectedParentHash string) bool {
-	if node.Block.ParentHash != expectedParentHash {
+// forkRoot returns the node fork choice starts from: the finalized head or, if it is higher, the
+// canonical block at the pruned height. Branches forking below it have no undo journals left.
+func (bc *TriadBlockchain) forkRoot() *TriadNode {
+	finalized := bc.FinalizedNode()
+	if bc.settled != nil && bc.settled.Block.Index > finalized.Block.Index {
+		return bc.settled
+	}
+	return finalized
+}
+
+// validateBlock checks a block against its parent: the link and height, the hash over the header,
+// the body against the header's Merkle roots unless it was archived and, if verifySignature is set, the signature of the
+// validator's registered key. Every path that accepts a non-genesis block goes through it.
+func (bc *TriadBlockchain) validateBlock(parent, block *Block, verifySignature bool) error {
+	if block.ParentHash != parent.Hash {
+		return fmt.Errorf("block %s parent hash %s does not match %s", block.Hash, block.ParentHash, parent.Hash)
+	}
+	if block.Index != parent.Index+1 {
+		return fmt.Errorf("invalid block index %d for parent %s", block.Index, parent.Hash)
+	}
+	if block.Hash != block.calculateHash() {
+		return fmt.Errorf("block %s hash does not match its header", block.Hash)
+	}
+	// An archived body was checked before it was moved out
+	if !block.Archived && !block.verifyBody() {
+		return fmt.Errorf("block %s body does not match its header", block.Hash)
+	}
+	if !verifySignature {
+		return nil
+	}
+	pubKey, exists := bc.Consensus.PublicKey(block.Validator)
+	if !exists {
+		return fmt.Errorf("no registered key for validator %s", block.Validator)
+	}
+	// The hash and body were checked above; an archived block has no body to check again
+	if !block.signedBy(pubKey) {
+		return fmt.Errorf("invalid signature on block %s", block.Hash)
+	}
+	return nil
+}
+
+// ValidateTree validates the triad blockchain. The genesis block is not signed by any validator;
+// every other block must pass validateBlock against its parent.
+func (bc *TriadBlockchain) ValidateTree() bool {
+	if bc.Root == nil {
 		return false
 	}
-	if node.Block.Hash != node.Block.calculateHash() {
+	genesis := bc.Root.Block
+	if genesis.ParentHash != "0" || genesis.Hash != genesis.calculateHash() || !genesis.verifyBody() {
 		return false
 	}
-	for _, child := range node.Children {
-		if child != nil {
-			if !bc.validateNode(child, node.Block.Hash) {
+	// Walk iteratively so a deep tree cannot exhaust the stack
+	it := bc.BFS(bc.Root)
+	for node := it.Next(); node != nil; node = it.Next() {
+		for _, child := range node.Children {
+			if child == nil {
+				continue
+			}
+			if bc.Nodes[child.Block.Hash] != child || bc.validateBlock(node.Block, child.Block, true) != nil {
 				return false
 			}
 		}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/Artfain/triad-networks/api"
 	"github.com/Artfain/triad-networks/core"
@@ -11,8 +16,55 @@ import (
 )
 
 func main() {
-	// Initialize state
-	state := core.NewState()
+	dbPath := flag.String("db", "data.db", "path to the LevelDB database")
+	inMemory := flag.Bool("memory", false, "keep all data in memory instead of LevelDB")
+	mempoolSize := flag.Int("mempool-size", 10000, "maximum number of pending transactions")
+	minFee := flag.Int64("min-fee", 0, "minimum fee per byte accepted into the mempool")
+	validatorKey := flag.String("validator-key", "", "hex-encoded secp256k1 private key; enables block production")
+	maxBlockTxs := flag.Int("max-block-txs", 500, "maximum number of transactions per block")
+	genesisStake := flag.Int64("genesis-stake", 0, "bond this stake to the validator key's account on a new chain")
+	config := core.DefaultStateConfig()
+	flag.DurationVar(&config.SlotDuration, "slot", config.SlotDuration, "block production slot duration")
+	flag.BoolVar(&config.Pruning.Archive, "archive", false, "run an archive node that never prunes blocks")
+	flag.IntVar(&config.Pruning.Depth, "prune-depth", config.Pruning.Depth, "drop side branches forking this many blocks below the head; 0 waits for finality")
+	blockArchive := flag.String("block-archive", "", "compressed archive file that settled canonical block bodies are moved into")
+	flag.Int64Var(&config.Emission.InitialReward, "block-reward", config.Emission.InitialReward, "tokens minted by the first block on a new chain")
+	flag.IntVar(&config.Emission.HalvingInterval, "halving-interval", config.Emission.HalvingInterval, "blocks between block reward halvings; 0 disables halving on a new chain")
+	flag.Int64Var(&config.Emission.DecayPercent, "reward-decay", config.Emission.DecayPercent, "percent the block reward shrinks each epoch when halving is disabled on a new chain")
+	flag.Int64Var(&config.Emission.MaxSupply, "max-supply", config.Emission.MaxSupply, "cap on tokens minted by block rewards; 0 for no cap on a new chain")
+	flag.Int64Var(&config.Emission.PoCPercent, "poc-share", config.Emission.PoCPercent, "percent of each block's emission paid into the PoC reward pool on a new chain")
+	flag.Int64Var(&config.Emission.FeeBurnPercent, "fee-burn", config.Emission.FeeBurnPercent, "percent of transaction fees burned instead of paid to the validator on a new chain")
+	flag.Parse()
+
+	// Open the shared database handle
+	store := core.NewMemoryBackedStore()
+	if !*inMemory {
+		var err error
+		store, err = core.OpenStore(*dbPath)
+		if err != nil {
+			slog.Error("Failed to open store", "path", *dbPath, "error", err)
+			return
+		}
+	}
+	defer store.Close()
+	if *blockArchive != "" {
+		archive, err := core.OpenBlockArchive(*blockArchive)
+		if err != nil {
+			slog.Error("Failed to open block archive", "path", *blockArchive, "error", err)
+			return
+		}
+		store.SetArchive(archive)
+	}
+
+	// Initialize state by replaying the persisted triad tree
+	state, err := core.NewState(store, config)
+	if err != nil {
+		slog.Error("Failed to load state", "error", err)
+		return
+	}
+
+	// Pending transactions wait here until a block includes them
+	mempool := core.NewMempool(state, *mempoolSize, *minFee)
 
 	// Initialize P2P network
 	p2p, err := p2p.NewP2P(state)
@@ -23,20 +75,52 @@ func main() {
 	// Print multiaddr for this node
 	fmt.Println("P2P multiaddr:", p2p.Host().Addrs()[0].String()+"/p2p/"+p2p.Host().ID().String())
 
-	// Start WebSocket server
-	http.HandleFunc("/ws", api.HandleWebSocket)
-	http.HandleFunc("/nodes", api.GetNodes)
-	http.HandleFunc("/tokens", api.GetTokens)
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	// Register the WebSocket and REST API over the node's single state
+	mux := http.NewServeMux()
+	api.NewServer(state, mempool).RegisterHandlers(mux)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "client/index.html")
 	})
 
-	// Start REST API in a separate goroutine
-	go api.SetupREST(state)
+	// Shut down cleanly on interrupt so the database is closed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Produce blocks in the slots this node leads
+	if *validatorKey != "" {
+		producer, err := core.NewBlockProducer(state, mempool, core.ProducerConfig{
+			MaxBlockTxs: *maxBlockTxs,
+			PrivateKey:  *validatorKey,
+		}, p2p)
+		if err != nil {
+			slog.Error("Failed to create block producer", "error", err)
+			return
+		}
+		// A restarted node has its genesis validators back from the store
+		genesis, err := store.GenesisValidators()
+		if err != nil {
+			slog.Error("Failed to load genesis validators", "error", err)
+			return
+		}
+		if *genesisStake > 0 && len(genesis) == 0 {
+			pubKey, _ := core.PublicKeyFromPrivate(*validatorKey)
+			if err := state.AddGenesisValidator(producer.Address(), "genesis", pubKey, *genesisStake); err != nil {
+				slog.Error("Failed to add genesis validator", "error", err)
+				return
+			}
+		}
+		go producer.Run(ctx)
+	}
+	server := &http.Server{Addr: ":8080", Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
 
 	// Start server
 	slog.Info("Starting server", "websocket", "ws://localhost:8080/ws", "http", "http://localhost:8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		slog.Error("Failed to start server", "error", err)
 	}
+	slog.Info("Shutting down")
 }
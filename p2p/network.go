@@ -15,6 +15,11 @@ import (
 	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
+const (
+	blockProtocol = protocol.ID("/triad/1.0.0")
+	voteProtocol  = protocol.ID("/triad/vote/1.0.0")
+)
+
 // P2P manages the peer-to-peer network.
 type P2P struct {
 	host  host.Host
@@ -33,7 +38,8 @@ func NewP2P(state *core.State) (*P2P, error) {
 		peers: make(map[peer.ID]struct{}),
 		state: state,
 	}
-	h.SetStreamHandler(protocol.ID("/triad/1.0.0"), p.handleStream)
+	h.SetStreamHandler(blockProtocol, p.handleStream)
+	h.SetStreamHandler(voteProtocol, p.handleVoteStream)
 	return p, nil
 }
 
@@ -55,18 +61,29 @@ func (p *P2P) AddPeer(multiaddr string) error {
 
 // BroadcastBlock broadcasts a new block to all peers.
 func (p *P2P) BroadcastBlock(block *core.Block) {
+	data, _ := json.Marshal(block)
+	p.broadcast(blockProtocol, data)
+}
+
+// BroadcastVote broadcasts a finality vote to all peers.
+func (p *P2P) BroadcastVote(vote *core.Vote) {
+	data, _ := json.Marshal(vote)
+	p.broadcast(voteProtocol, data)
+}
+
+// broadcast sends data to all peers over the given protocol.
+func (p *P2P) broadcast(protocolID protocol.ID, data []byte) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
-	data, _ := json.Marshal(block)
 	for peerID := range p.peers {
-		stream, err := p.host.NewStream(context.Background(), peerID, protocol.ID("/triad/1.0.0"))
+		stream, err := p.host.NewStream(context.Background(), peerID, protocolID)
 		if err != nil {
 			slog.Error("Failed to open stream to peer", "peer", peerID, "error", err)
 			continue
 		}
 		_, err = stream.Write(data)
 		if err != nil {
-			slog.Error("Failed to send block to peer", "peer", peerID, "error", err)
+			slog.Error("Failed to send to peer", "peer", peerID, "protocol", protocolID, "error", err)
 		}
 		stream.Close()
 	}
@@ -87,8 +104,29 @@ func (p *P2P) handleStream(stream network.Stream) {
 		return
 	}
 	slog.Info("Received block from peer", "peer", stream.Conn().RemotePeer().String(), "block", block.Hash)
-	// Add received block to the triad tree
-	p.state.AddBlock(block.Data, block.Validator, block.ParentHash)
+	// Add received block to the triad tree, rejecting forged signatures
+	if err := p.state.AddBlock(&block); err != nil {
+		slog.Error("Rejected block from peer", "peer", stream.Conn().RemotePeer().String(), "block", block.Hash, "error", err)
+	}
+}
+
+// handleVoteStream handles incoming finality votes from peers.
+func (p *P2P) handleVoteStream(stream network.Stream) {
+	defer stream.Close()
+	buf := make([]byte, 64*1024)
+	n, err := stream.Read(buf)
+	if err != nil {
+		slog.Error("Failed to read from vote stream", "error", err)
+		return
+	}
+	var vote core.Vote
+	if err := json.Unmarshal(buf[:n], &vote); err != nil {
+		slog.Error("Failed to unmarshal vote", "error", err)
+		return
+	}
+	if err := p.state.AddVote(&vote); err != nil {
+		slog.Error("Rejected vote from peer", "peer", stream.Conn().RemotePeer().String(), "block", vote.BlockHash, "error", err)
+	}
 }
 
 // Host returns the libp2p host.
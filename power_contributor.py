@@ -15,25 +15,49 @@ def generate_keypair():
 def sign_message(private_key, message):
     sk = ecdsa.SigningKey.from_string(bytes.fromhex(private_key), curve=ecdsa.SECP256k1)
     message_hash = hashlib.sha256(message.encode()).digest()
-    signature = sk.sign(message_hash)
+    signature = sk.sign_digest(message_hash)
     return base64.b64encode(signature).decode()
 
-def contribute_power(address, device_id, cpu_load):
+def make_transaction(private_key, public_key, address, tx_type, nonce, payload):
+    # Field order and omitted empty fields must match the node's signing payload
+    payload_json = json.dumps(payload, separators=(',', ':'))
+    signed = json.dumps({
+        "type": tx_type,
+        "from": address,
+        "to": "",
+        "amount": 0,
+        "timestamp": time.time_ns(),
+        "nonce": nonce,
+        "prevHash": "",
+        "publicKey": public_key
+    }, separators=(',', ':'))
+    message = signed[:-1] + ',"payload":' + payload_json + '}'
+    tx = json.loads(signed)
+    tx["payload"] = payload
+    tx["signature"] = sign_message(private_key, message)
+    return tx
+
+def send_transaction(ws, private_key, public_key, address, tx_type, nonce, payload):
+    tx = make_transaction(private_key, public_key, address, tx_type, nonce, payload)
+    ws.send(json.dumps({"type": tx_type, "data": tx}))
+    return json.loads(ws.recv())
+
+def contribute_power(private_key, address, public_key, device_id, cpu_load):
     ws = websocket.WebSocket()
     ws.connect("ws://localhost:8080/ws")
     
     # Register user
-    register_msg = {
-        "type": "register",
-        "data": {
-            "address": address,
-            "deviceID": device_id
-        }
-    }
-    ws.send(json.dumps(register_msg))
-    response = json.loads(ws.recv())
+    nonce = 1
+    response = send_transaction(ws, private_key, public_key, address, "register", nonce, {"deviceID": device_id})
     print(f"Registration response: {response}")
 
+    # Wait for the registration to be included in a block
+    while True:
+        ws.send(json.dumps({"type": "get_data", "data": {"address": address}}))
+        if "error" not in json.loads(ws.recv()):
+            break
+        time.sleep(5)
+
     # Simulate contribution
     while True:
         computations = int(cpu_load * 1000)
@@ -46,18 +70,15 @@ def contribute_power(address, device_id, cpu_load):
             "uptime": int(time.time()),
             "ecoActions": eco_actions
         }
-        contribute_msg = {
-            "type": "contribute",
-            "data": {
-                "address": address,
-                "deviceID": device_id,
-                "contribution": contribution,
-                "trees": trees_planted
-            }
-        }
-        ws.send(json.dumps(contribute_msg))
-        response = json.loads(ws.recv())
+        nonce += 1
+        response = send_transaction(ws, private_key, public_key, address, "contribute", nonce,
+                                    {"deviceID": device_id, "contribution": contribution})
         print(f"Contribution response: {response}")
+        if trees_planted > 0:
+            nonce += 1
+            response = send_transaction(ws, private_key, public_key, address, "eco_action", nonce,
+                                        {"trees": trees_planted})
+            print(f"Eco action response: {response}")
 
         # Request user data
         data_msg = {
@@ -99,6 +120,6 @@ if __name__ == "__main__":
     device_id = "macbook"
     
     try:
-        contribute_power(address, device_id, cpu_load)
+        contribute_power(private_key, address, public_key, device_id, cpu_load)
     except KeyboardInterrupt:
         print("Stopped contributing")
\ No newline at end of file